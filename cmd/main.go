@@ -8,12 +8,91 @@ import (
 
 	"github.com/urfave/cli/v2"
 	"github.com/woojiahao/baleen/internal/baleen"
+	"github.com/woojiahao/baleen/internal/notion"
+	"github.com/woojiahao/baleen/internal/trello"
+	"github.com/woojiahao/baleen/internal/types"
 )
 
 // TODO: Support general migrations from Trello to Notion
 func main() {
-	var boardName, envPath, configPath, savePath string
-	var toSave bool
+	var boardName, envPath, configPath, savePath, planPath, parentPage, trelloJsonPath string
+	var outPath, saveDir, saveName, statePath string
+	var toSave, resume, dryRun, includeArchived, onlyArchived, diffJson, incremental bool
+	var rate float64
+	var concurrency int
+
+	// Flags shared by the commands that import into Notion
+	importFlags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "print the changes that would be made to Notion without making them",
+			Destination: &dryRun,
+		},
+		&cli.StringFlag{
+			Name:        "plan",
+			Usage:       "specify a file to write the full JSON payloads of a dry run to",
+			Destination: &planPath,
+		},
+		&cli.StringFlag{
+			Name:        "parent-page",
+			Usage:       "specify the ID or URL of a Notion page to create missing databases under",
+			Destination: &parentPage,
+		},
+	}
+
+	// Flags shared by the commands that export from Trello
+	exportFlags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "include-archived",
+			Usage:       "also export archived cards and the cards in archived lists",
+			Destination: &includeArchived,
+		},
+		&cli.BoolFlag{
+			Name:        "only-archived",
+			Usage:       "only export archived cards and the cards in archived lists",
+			Destination: &onlyArchived,
+		},
+	}
+
+	// Flags shared by the commands that write a save file
+	saveFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "out",
+			Aliases:     []string{"o"},
+			Usage:       "specify the path of the save file, overriding --save-dir and --save-name",
+			Destination: &outPath,
+		},
+		&cli.StringFlag{
+			Name:        "save-dir",
+			Value:       "data/saves",
+			Usage:       "specify the folder to write the save file to",
+			Destination: &saveDir,
+		},
+		&cli.StringFlag{
+			Name:  "save-name",
+			Value: types.DefaultSaveName,
+			Usage: "specify the name of the save file, where {board}, {date} and {count} are replaced by the board " +
+				"name, export time and number of cards (the extension picks the format: .json or .ndjson, optionally " +
+				"followed by .gz or .zst)",
+			Destination: &saveName,
+		},
+	}
+
+	saveLocation := func() types.SaveLocation {
+		return types.SaveLocation{Out: outPath, Dir: saveDir, Name: saveName}
+	}
+
+	exportOptions := func() (trello.ExportOptions, error) {
+		if includeArchived && onlyArchived {
+			return trello.ExportOptions{}, fmt.Errorf("--include-archived and --only-archived cannot be used together")
+		}
+
+		return trello.ExportOptions{
+			Concurrency:     concurrency,
+			IncludeArchived: includeArchived,
+			OnlyArchived:    onlyArchived,
+		}, nil
+	}
 
 	app := &cli.App{
 		Name:  "baleen",
@@ -23,7 +102,7 @@ func main() {
 				Name:        "board",
 				Aliases:     []string{"b"},
 				Value:       "Programming Bucket",
-				Usage:       "specify name of Trello board",
+				Usage:       "specify the name, ID, short link or URL of the Trello board",
 				Destination: &boardName,
 			},
 			&cli.StringFlag{
@@ -40,11 +119,23 @@ func main() {
 				Usage:       "specify the configuration JSON for the migration",
 				Destination: &configPath,
 			},
+			&cli.Float64Flag{
+				Name:        "rate",
+				Value:       notion.DefaultRequestsPerSecond,
+				Usage:       "specify the maximum number of requests sent to Notion per second",
+				Destination: &rate,
+			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Value:       3,
+				Usage:       "specify the number of cards exported from Trello or imported into Notion at the same time",
+				Destination: &concurrency,
+			},
 		},
 		Commands: []*cli.Command{
 			{
 				Name: "migrate",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.BoolFlag{
 						Name:        "save",
 						Aliases:     []string{"s"},
@@ -52,37 +143,154 @@ func main() {
 						Usage:       "specify whether to save files during migration (used in \"baleen migrate\")",
 						Destination: &toSave,
 					},
-				},
+					&cli.BoolFlag{
+						Name: "incremental",
+						Usage: "only migrate the cards with activity since the last incremental migration of the board (add " +
+							"--include-archived to mark the cards archived since then as archived, otherwise they are never synced; " +
+							"cards deleted from Trello are never removed from Notion)",
+						Destination: &incremental,
+					},
+					&cli.BoolFlag{
+						Name:    "resume",
+						Aliases: []string{"r"},
+						Usage: "skip cards that the last migration of the board imported, as recorded in the board's " +
+							"journal in data/journals",
+						Destination: &resume,
+					},
+					&cli.StringFlag{
+						Name:        "state",
+						Value:       "data/state.json",
+						Usage:       "specify the file recording the progress of incremental migrations",
+						Destination: &statePath,
+					},
+				}, append(append(importFlags, exportFlags...), saveFlags...)...),
 				Usage: "exports a Trello board into the integrated Notion page (full flow from saving exports to importing to Notion)",
 				Action: func(c *cli.Context) error {
-					baleen.Migrate(boardName, configPath, envPath, toSave)
+					options, err := exportOptions()
+					if err != nil {
+						return err
+					}
+
+					baleen.Migrate(
+						boardName,
+						configPath,
+						envPath,
+						toSave,
+						saveLocation(),
+						incremental,
+						statePath,
+						options,
+						notion.ImportOptions{
+							RequestsPerSecond: rate,
+							Resume:            resume,
+							Concurrency:       concurrency,
+							DryRun:            dryRun,
+							PlanPath:          planPath,
+							ParentPage:        parentPage,
+						},
+					)
 					return nil
 				},
 			},
 			{
 				Name:  "import",
 				Usage: "imports saved cards into Notion (cards saved from \"baleen migrate\" or \"baleen export\")",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:        "savePath",
 						Aliases:     []string{"sp"},
-						Usage:       "specify the path of a save file",
+						Usage:       "specify the path of a save file (.json or .ndjson, optionally compressed with .gz or .zst)",
 						Destination: &savePath,
 					},
-				},
+					&cli.BoolFlag{
+						Name:        "resume",
+						Aliases:     []string{"r"},
+						Usage:       "skip cards recorded as imported in the journal next to the save file",
+						Destination: &resume,
+					},
+				}, importFlags...),
 				Action: func(c *cli.Context) error {
 					if savePath == "" {
 						return fmt.Errorf("save path not specified")
 					}
-					baleen.Import(savePath, configPath, envPath)
+					baleen.Import(savePath, configPath, envPath, notion.ImportOptions{
+						Resume:            resume,
+						RequestsPerSecond: rate,
+						Concurrency:       concurrency,
+						DryRun:            dryRun,
+						PlanPath:          planPath,
+						ParentPage:        parentPage,
+					})
 					return nil
 				},
 			},
 			{
 				Name:  "export",
 				Usage: "exports a Trello board and creates a save file (to import, use \"baleen import <save path>\"",
+				Flags: append(exportFlags, saveFlags...),
+				Action: func(c *cli.Context) error {
+					options, err := exportOptions()
+					if err != nil {
+						return err
+					}
+
+					baleen.ExportAndSave(boardName, envPath, options, saveLocation())
+					return nil
+				},
+			},
+			{
+				Name:  "convert",
+				Usage: "converts a board exported as JSON from Trello into a save file (no Trello API keys needed)",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:        "from-trello-json",
+						Usage:       "specify the path of the JSON file exported from the board's \"Print and export\" menu",
+						Required:    true,
+						Destination: &trelloJsonPath,
+					},
+				}, append(exportFlags, saveFlags...)...),
+				Action: func(c *cli.Context) error {
+					options, err := exportOptions()
+					if err != nil {
+						return err
+					}
+
+					baleen.ConvertAndSave(trelloJsonPath, options, saveLocation())
+					return nil
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "compares two saves of a board, listing the cards that were added, removed, moved or changed",
+				ArgsUsage: "<older save path> <newer save path>",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:        "json",
+						Usage:       "print the changes as JSON instead of a table",
+						Destination: &diffJson,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 2 {
+						return fmt.Errorf("expected the paths of two saves, got %d", c.NArg())
+					}
+					baleen.Diff(c.Args().Get(0), c.Args().Get(1), diffJson)
+					return nil
+				},
+			},
+			{
+				Name:  "boards",
+				Usage: "lists the Trello boards that can be exported, with their IDs, organisations and number of open cards",
+				Action: func(c *cli.Context) error {
+					baleen.ListBoards(envPath)
+					return nil
+				},
+			},
+			{
+				Name:  "users",
+				Usage: "lists the users of the Notion workspace (to fill in the \"memberMapping\" of the configuration)",
 				Action: func(c *cli.Context) error {
-					baleen.ExportAndSave(boardName, envPath)
+					baleen.ListNotionUsers(envPath)
 					return nil
 				},
 			},
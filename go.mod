@@ -3,14 +3,18 @@ module github.com/woojiahao/baleen
 go 1.17
 
 require (
-	github.com/adlio/trello v1.9.0 // indirect
+	github.com/adlio/trello v1.9.0
+	github.com/joho/godotenv v1.4.0
+	github.com/jomei/notionapi v1.7.3
+	github.com/klauspost/compress v1.15.15
+	github.com/urfave/cli/v2 v2.3.0
+	golang.org/x/net v0.0.0-20220127200216-cd36cc0744dd
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
+)
+
+require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
-	github.com/joho/godotenv v1.4.0 // indirect
-	github.com/jomei/notionapi v1.7.3 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/russross/blackfriday/v2 v2.0.1 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
-	github.com/urfave/cli/v2 v2.3.0 // indirect
-	golang.org/x/net v0.0.0-20220127200216-cd36cc0744dd // indirect
-	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e // indirect
 )
@@ -0,0 +1,222 @@
+package attachment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Largest attachment that will be downloaded. Trello itself caps uploads at 250MB, so anything larger is not a file
+// that Trello served.
+const maxDownloadSize = 250 << 20
+
+// Attachment downloaded into the cache
+type File struct {
+	// Name of the attachment on Trello
+	Name string
+	// Location of the file in the cache
+	Path string
+	// SHA-256 of the file contents, which the file is stored under
+	Hash     string
+	MimeType string
+}
+
+// Extension of the file, taken from its name
+func (f *File) Ext() string {
+	return strings.ToLower(path.Ext(f.Name))
+}
+
+// Local content-addressed cache of Trello attachments. Files are stored under the hash of their contents so the same
+// file attached to several cards is only stored once, and every downloaded URL records the hash of its file so that
+// it is never downloaded twice.
+type Cache struct {
+	dir         string
+	trelloKey   string
+	trelloToken string
+	client      *http.Client
+	maxSize     int64
+}
+
+func NewCache(dir, trelloKey, trelloToken string) (*Cache, error) {
+	for _, subfolder := range []string{"files", "urls", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, subfolder), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create attachment cache in %s: %v", dir, err)
+		}
+	}
+
+	return &Cache{
+		dir:         dir,
+		trelloKey:   trelloKey,
+		trelloToken: trelloToken,
+		client:      &http.Client{Timeout: 5 * time.Minute},
+		maxSize:     maxDownloadSize,
+	}, nil
+}
+
+// Returns the cached file for the attachment, downloading it first if it has not been cached
+func (c *Cache) Fetch(name, attachmentUrl string) (*File, error) {
+	urlPath := filepath.Join(c.dir, "urls", hash([]byte(attachmentUrl)))
+
+	if contentHash, err := ioutil.ReadFile(urlPath); err == nil {
+		filePath := c.filePath(string(contentHash))
+		if _, err := os.Stat(filePath); err == nil {
+			return c.file(name, string(contentHash)), nil
+		}
+	}
+
+	contentHash, err := c.download(attachmentUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeAtomic(filepath.Join(c.dir, "tmp"), urlPath, []byte(contentHash)); err != nil {
+		return nil, err
+	}
+
+	return c.file(name, contentHash), nil
+}
+
+func (c *Cache) file(name, contentHash string) *File {
+	mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	if mimeType == "" {
+		mimeType = sniffMimeType(c.filePath(contentHash))
+	}
+
+	return &File{
+		Name:     name,
+		Path:     c.filePath(contentHash),
+		Hash:     contentHash,
+		MimeType: mimeType,
+	}
+}
+
+func (c *Cache) filePath(contentHash string) string {
+	return filepath.Join(c.dir, "files", contentHash)
+}
+
+// Downloads the attachment into the cache and returns the hash of its contents
+func (c *Cache) download(attachmentUrl string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, attachmentUrl, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Files uploaded to Trello can only be downloaded by members of the board. Other hosts (such as the S3 bucket
+	// that older attachments live in) must not receive the credentials.
+	if isTrelloUrl(attachmentUrl) {
+		req.Header.Set(
+			"Authorization",
+			fmt.Sprintf(`OAuth oauth_consumer_key="%s", oauth_token="%s"`, c.trelloKey, c.trelloToken),
+		)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", attachmentUrl, res.Status)
+	}
+
+	if res.ContentLength > c.maxSize {
+		return "", c.tooLarge(attachmentUrl)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(c.dir, "tmp"), "download-")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	// The body is read one byte past the cap so that a file that is exactly the cap is told apart from a larger one
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(res.Body, c.maxSize+1))
+	if err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to download %s: %v", attachmentUrl, err)
+	}
+
+	if written > c.maxSize {
+		tmp.Close()
+		return "", c.tooLarge(attachmentUrl)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	contentHash := hex.EncodeToString(hasher.Sum(nil))
+	if err := os.Rename(tmp.Name(), c.filePath(contentHash)); err != nil {
+		return "", err
+	}
+
+	return contentHash, nil
+}
+
+func (c *Cache) tooLarge(attachmentUrl string) error {
+	return fmt.Errorf("failed to download %s: file is larger than %d bytes", attachmentUrl, c.maxSize)
+}
+
+func isTrelloUrl(attachmentUrl string) bool {
+	u, err := url.Parse(attachmentUrl)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	return host == "trello.com" || strings.HasSuffix(host, ".trello.com")
+}
+
+func sniffMimeType(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := file.Read(head)
+
+	return http.DetectContentType(head[:n])
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Writes the file through a temporary file so that readers never see it half written
+func writeAtomic(tmpDir, filePath string, data []byte) error {
+	tmp, err := ioutil.TempFile(tmpDir, "write-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
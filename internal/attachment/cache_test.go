@@ -0,0 +1,217 @@
+package attachment
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Sends every request to the test server whatever host it was made for, so that Trello URLs can be served locally
+type redirectTransport struct {
+	server *url.URL
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme = t.server.Scheme
+	req.URL.Host = t.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+type testServer struct {
+	*httptest.Server
+	files     map[string]string
+	downloads map[string]int
+	auth      map[string]string
+}
+
+func newTestServer(t *testing.T, files map[string]string) (*testServer, *Cache) {
+	s := &testServer{files: files, downloads: map[string]int{}, auth: map[string]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Host + r.URL.Path
+		s.downloads[key]++
+		s.auth[key] = r.Header.Get("Authorization")
+
+		content, ok := s.files[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+
+	cache, err := NewCache(t.TempDir(), "key", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, _ := url.Parse(s.URL)
+	cache.client = &http.Client{Transport: &redirectTransport{server}}
+
+	return s, cache
+}
+
+func TestCacheHitSkipsDownload(t *testing.T) {
+	s, cache := newTestServer(t, map[string]string{"trello.com/1/notes.txt": "hello"})
+
+	first, err := cache.Fetch("notes.txt", "https://trello.com/1/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cache.Fetch("notes.txt", "https://trello.com/1/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.downloads["trello.com/1/notes.txt"]; n != 1 {
+		t.Errorf("downloaded %d times, want once", n)
+	}
+
+	if first.Path != second.Path || first.Hash != hash([]byte("hello")) {
+		t.Errorf("fetched %+v then %+v, want the same file stored under its hash", first, second)
+	}
+}
+
+func TestCacheSharesFilesByContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		first  string
+		second string
+		same   bool
+	}{
+		{"renamed file", map[string]string{"trello.com/1/a.txt": "hello", "trello.com/2/b.txt": "hello"}, "https://trello.com/1/a.txt", "https://trello.com/2/b.txt", true},
+		{"different files", map[string]string{"trello.com/1/a.txt": "hello", "trello.com/2/a.txt": "world"}, "https://trello.com/1/a.txt", "https://trello.com/2/a.txt", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, cache := newTestServer(t, test.files)
+
+			first, err := cache.Fetch(filepath.Base(test.first), test.first)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			second, err := cache.Fetch(filepath.Base(test.second), test.second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (first.Path == second.Path) != test.same {
+				t.Errorf("paths %s and %s, want shared = %v", first.Path, second.Path, test.same)
+			}
+
+			if second.Name != filepath.Base(test.second) {
+				t.Errorf("name = %s, want the name it was fetched with", second.Name)
+			}
+
+			for _, file := range []*File{first, second} {
+				content, err := ioutil.ReadFile(file.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if hash(content) != file.Hash {
+					t.Errorf("%s holds content with hash %s, want %s", file.Path, hash(content), file.Hash)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheDownloadsAgainWhenFileIsMissing(t *testing.T) {
+	s, cache := newTestServer(t, map[string]string{"trello.com/1/notes.txt": "hello"})
+
+	first, err := cache.Fetch("notes.txt", "https://trello.com/1/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(first.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.Fetch("notes.txt", "https://trello.com/1/notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.downloads["trello.com/1/notes.txt"]; n != 2 {
+		t.Errorf("downloaded %d times, want the missing file downloaded again", n)
+	}
+}
+
+func TestOAuthHeaderIsOnlySentToTrello(t *testing.T) {
+	tests := []struct {
+		url  string
+		auth bool
+	}{
+		{"https://trello.com/1/a.txt", true},
+		{"https://api.trello.com/1/a.txt", true},
+		{"https://trello-attachments.s3.amazonaws.com/1/a.txt", false},
+		{"https://trello.com.example.com/1/a.txt", false},
+		{"https://nottrello.com/1/a.txt", false},
+	}
+
+	files := map[string]string{}
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		files[u.Host+u.Path] = test.url
+	}
+
+	s, cache := newTestServer(t, files)
+
+	for _, test := range tests {
+		if _, err := cache.Fetch("a.txt", test.url); err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ := url.Parse(test.url)
+		auth := s.auth[u.Host+u.Path]
+		if (auth != "") != test.auth {
+			t.Errorf("%s got Authorization %q, want sent = %v", test.url, auth, test.auth)
+		}
+
+		if test.auth && !strings.Contains(auth, `oauth_consumer_key="key", oauth_token="token"`) {
+			t.Errorf("%s got Authorization %q, want the Trello key and token", test.url, auth)
+		}
+	}
+}
+
+func TestDownloadsOverTheCapFail(t *testing.T) {
+	tests := []struct {
+		content string
+		fails   bool
+	}{
+		{"1234", false},
+		{"12345", true},
+	}
+
+	for _, test := range tests {
+		_, cache := newTestServer(t, map[string]string{"trello.com/1/a.txt": test.content})
+		cache.maxSize = 4
+
+		_, err := cache.Fetch("a.txt", "https://trello.com/1/a.txt")
+		if (err != nil) != test.fails {
+			t.Errorf("fetching %d bytes returned %v, want failure = %v", len(test.content), err, test.fails)
+		}
+
+		if test.fails && !strings.Contains(err.Error(), "larger than 4 bytes") {
+			t.Errorf("error = %v, want it to name the cap", err)
+		}
+
+		cached, _ := ioutil.ReadDir(filepath.Join(cache.dir, "files"))
+		if test.fails && len(cached) != 0 {
+			t.Errorf("cached %d files, want nothing kept from a failed download", len(cached))
+		}
+	}
+}
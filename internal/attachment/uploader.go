@@ -0,0 +1,96 @@
+package attachment
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/woojiahao/baleen/internal/config"
+)
+
+// Uploads cached attachments to somewhere Notion can embed them from. The Notion API can only embed files that are
+// hosted elsewhere, so every uploader must return a publicly reachable URL.
+type Uploader interface {
+	Upload(file *File) (string, error)
+}
+
+// Creates the uploader chosen in the attachments config
+func NewUploader(attachments *config.Attachments) (Uploader, error) {
+	switch attachments.Uploader {
+	case "local", "":
+		return NewLocalUploader(attachments.LocalDir, attachments.LocalBaseUrl)
+	default:
+		return nil, fmt.Errorf("unknown attachment uploader %s", attachments.Uploader)
+	}
+}
+
+// Copies attachments into a local directory that is served at baseUrl, such as a folder shared by a static file
+// server. Mostly useful for testing the attachment pipeline.
+type LocalUploader struct {
+	dir     string
+	baseUrl *url.URL
+}
+
+func NewLocalUploader(dir, baseUrl string) (*LocalUploader, error) {
+	if dir == "" || baseUrl == "" {
+		return nil, fmt.Errorf("the local uploader needs both localDir and localBaseUrl to be set")
+	}
+
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid localBaseUrl %s: %v", baseUrl, err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create upload folder %s: %v", dir, err)
+	}
+
+	return &LocalUploader{dir, u}, nil
+}
+
+func (u *LocalUploader) Upload(file *File) (string, error) {
+	name := file.Hash + file.Ext()
+	destination := filepath.Join(u.dir, name)
+
+	if _, err := os.Stat(destination); os.IsNotExist(err) {
+		if err := copyFile(file.Path, destination); err != nil {
+			return "", err
+		}
+	}
+
+	fileUrl := *u.baseUrl
+	fileUrl.Path = strings.TrimSuffix(fileUrl.Path, "/") + "/" + name
+
+	return fileUrl.String(), nil
+}
+
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(destination), ".upload-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), destination)
+}
@@ -0,0 +1,66 @@
+package attachment
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalUploaderCopiesByContentHash(t *testing.T) {
+	source := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "uploads")
+
+	uploader, err := NewLocalUploader(dir, "https://files.example.com/baleen/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"Photo.PNG", "image", "https://files.example.com/baleen/" + hash([]byte("image")) + ".png"},
+		{"copy.png", "image", "https://files.example.com/baleen/" + hash([]byte("image")) + ".png"},
+		{"notes", "text", "https://files.example.com/baleen/" + hash([]byte("text"))},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(source, test.name)
+		if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := uploader.Upload(&File{Name: test.name, Path: path, Hash: hash([]byte(test.content))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != test.want {
+			t.Errorf("Upload(%s) = %s, want %s", test.name, got, test.want)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(got)))
+		if err != nil || string(content) != test.content {
+			t.Errorf("uploaded %s holds %q (%v), want %q", test.name, content, err, test.content)
+		}
+	}
+
+	uploaded, _ := ioutil.ReadDir(dir)
+	if len(uploaded) != 2 {
+		t.Errorf("uploaded %d files, want files with the same content stored once", len(uploaded))
+	}
+}
+
+func TestLocalUploaderNeedsFolderAndUrl(t *testing.T) {
+	tests := []struct{ dir, baseUrl string }{
+		{"", "https://files.example.com"},
+		{t.TempDir(), ""},
+	}
+
+	for _, test := range tests {
+		if _, err := NewLocalUploader(test.dir, test.baseUrl); err == nil {
+			t.Errorf("NewLocalUploader(%q, %q) succeeded, want an error", test.dir, test.baseUrl)
+		}
+	}
+}
@@ -1,36 +1,135 @@
 package baleen
 
 import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/woojiahao/baleen/internal/notion"
 	"github.com/woojiahao/baleen/internal/trello"
 	"github.com/woojiahao/baleen/internal/types"
 )
 
-const (
-	savePath = "saves"
-)
+// Folder of the journals of migrations, one for each board
+const journalDir = "data/journals"
+
+// Performs full migration from Trello board to Notion. Imported cards are recorded in a journal for the board, so that
+// resuming a migration skips the cards that the last migration of the board imported. An incremental migration only
+// migrates the cards with activity since the last incremental migration of the board that imported every card, as
+// recorded in the state file. Cards deleted from Trello are never removed from Notion, and cards archived since then
+// are only marked as archived when archived cards are included, as Trello only lists the cards that still exist and are
+// in the lists being exported.
+func Migrate(
+	trelloBoardName, configPath, envPath string,
+	toSave bool,
+	location types.SaveLocation,
+	incremental bool,
+	statePath string,
+	exportOptions trello.ExportOptions,
+	options notion.ImportOptions,
+) {
+	var state *migrationState
+	if incremental {
+		state = loadState(statePath)
+		exportOptions.ChangedSince = state.highWaterMark(trello.BoardId(trelloBoardName, envPath))
+		if exportOptions.ChangedSince != nil {
+			log.Printf("Migrating cards with activity since %s\n", exportOptions.ChangedSince.Format(time.RFC3339))
+		}
+	}
+
+	startedAt := time.Now().UTC()
+	save := trello.ExportTrelloBoard(trelloBoardName, envPath, exportOptions)
+
+	if incremental && len(save.Cards) == 0 {
+		log.Printf("No cards changed since the last migration\n")
+		if !options.DryRun {
+			state.record(save, startedAt)
+			state.save(statePath)
+		}
+		return
+	}
 
-// Performs full migration from Trello board to Notion
-func Migrate(trelloBoardName, configPath, envPath string, toSave bool) {
-	cards := trello.ExportTrelloBoard(trelloBoardName, envPath)
 	if toSave {
-		types.SaveCards(cards, savePath)
+		types.SaveCards(save, location)
 	}
 
-	notion.ImportToNotion(cards, envPath, configPath)
+	// Every migration writes a new save, so the journal is kept per board for a later migration to resume from
+	options.JournalPath = filepath.Join(journalDir, save.Board.Id+".journal")
+
+	failed := notion.ImportToNotion(save, types.CardSlice(save.Cards), envPath, configPath, options)
+
+	if !incremental || options.DryRun {
+		return
+	}
+
+	// Leaving the mark where it was makes the next migration retry the cards that failed
+	if failed > 0 {
+		log.Printf("%d cards failed to import, not recording this migration in %s\n", failed, statePath)
+		return
+	}
+
+	state.record(save, startedAt)
+	state.save(statePath)
+	log.Printf("Recorded migration in %s\n", statePath)
+}
+
+// Imports into Notion from existing save file. Imported cards are recorded in a journal next to the save file so that
+// resuming skips the cards that have already been imported.
+func Import(savePath, configPath, envPath string, options notion.ImportOptions) {
+	save, cards := notion.LoadSave(savePath)
+	options.JournalPath = notion.JournalPath(savePath)
+	notion.ImportToNotion(save, cards, envPath, configPath, options)
 }
 
-// Imports into Notion from existing save file
-func Import(savePath, configPath, envPath string) {
-	cards := notion.LoadSave(savePath)
-	notion.ImportToNotion(cards, envPath, configPath)
+func ExportAndSave(trelloBoardName, envPath string, options trello.ExportOptions, location types.SaveLocation) {
+	save := trello.ExportTrelloBoard(trelloBoardName, envPath, options)
+	types.SaveCards(save, location)
 }
 
-func ExportAndSave(trelloBoardName, envPath string) {
-	cards := trello.ExportTrelloBoard(trelloBoardName, envPath)
-	types.SaveCards(cards, savePath)
+// Converts a board exported as JSON from Trello into a save file, for boards that cannot be accessed through the API
+func ConvertAndSave(trelloExportPath string, options trello.ExportOptions, location types.SaveLocation) {
+	save := trello.ParseBoardExport(trelloExportPath, options)
+	types.SaveCards(save, location)
 }
 
 func ClearBoard(trelloBoardName, envPath string) {
 	trello.ArchiveAll(trelloBoardName, envPath)
 }
+
+// Lists the users of the Notion workspace for mapping Trello members to
+func ListNotionUsers(envPath string) {
+	notion.ListUsers(envPath)
+}
+
+// Lists the Trello boards that can be exported
+func ListBoards(envPath string) {
+	trello.ListBoards(envPath)
+}
+
+// Prints the changes between two saves of a board, either as a table or as JSON
+func Diff(olderPath, newerPath string, asJson bool) {
+	_, older, err := types.OpenCards(olderPath)
+	if err != nil {
+		log.Fatalf("Failed to load save %s: %v\n", olderPath, err)
+	}
+
+	_, newer, err := types.OpenCards(newerPath)
+	if err != nil {
+		log.Fatalf("Failed to load save %s: %v\n", newerPath, err)
+	}
+
+	diff, err := types.DiffSaves(older, newer)
+	if err != nil {
+		log.Fatalf("Failed to compare saves: %v\n", err)
+	}
+
+	if !asJson {
+		diff.PrintTable(os.Stdout)
+		return
+	}
+
+	data, _ := json.MarshalIndent(diff, "", "  ")
+	os.Stdout.Write(append(data, '\n'))
+}
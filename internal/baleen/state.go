@@ -0,0 +1,88 @@
+package baleen
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+// Progress of incremental migrations, keyed by the ID of the board migrated
+type migrationState struct {
+	Boards map[string]*boardState `json:"boards"`
+}
+
+type boardState struct {
+	Name string `json:"name"`
+	// Latest activity on the cards migrated so far. The next incremental migration only exports the cards with activity
+	// after it.
+	HighWaterMark *time.Time `json:"highWaterMark"`
+	// When the last migration of the board that imported every card started
+	LastRun *time.Time `json:"lastRun"`
+}
+
+// Loads the state of incremental migrations, starting afresh when there is no state file yet
+func loadState(statePath string) *migrationState {
+	state := &migrationState{Boards: make(map[string]*boardState)}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		log.Printf("No state found at %s, migrating every card\n", statePath)
+		return state
+	}
+	if err != nil {
+		log.Fatalf("Failed to read state %s: %v\n", statePath, err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		log.Fatalf("Failed to parse state %s: %v\n", statePath, err)
+	}
+	if state.Boards == nil {
+		state.Boards = make(map[string]*boardState)
+	}
+
+	return state
+}
+
+func (state *migrationState) save(statePath string) {
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := types.WriteFileAtomic(statePath, data); err != nil {
+		log.Fatalf("Failed to save state to %s: %v\n", statePath, err)
+	}
+}
+
+// High-water mark of the board, nil when the board has not been migrated before
+func (state *migrationState) highWaterMark(boardId string) *time.Time {
+	if board, ok := state.Boards[boardId]; ok {
+		return board.HighWaterMark
+	}
+
+	return nil
+}
+
+// Records that the cards in the save were migrated, moving the board's high-water mark up to the latest activity on
+// them. The mark is kept at or before when the export started, as a card can change after it is exported but before
+// the cards after it are. The mark never moves back, so a save without any cards keeps the previous mark.
+func (state *migrationState) record(save *types.Save, startedAt time.Time) {
+	board, ok := state.Boards[save.Board.Id]
+	if !ok {
+		board = &boardState{}
+		state.Boards[save.Board.Id] = board
+	}
+
+	board.Name = save.Board.Name
+	board.LastRun = &startedAt
+
+	for _, card := range save.Cards {
+		mark := card.LastUpdate
+		if mark != nil && mark.After(startedAt) {
+			mark = &startedAt
+		}
+
+		if mark != nil && (board.HighWaterMark == nil || mark.After(*board.HighWaterMark)) {
+			board.HighWaterMark = mark
+		}
+	}
+}
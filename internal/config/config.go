@@ -4,12 +4,154 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"net/url"
 	"os"
+	"strings"
 )
 
 type Config struct {
-	Database map[string]string `json:"databaseMapping"`
-	Color    map[string]string `json:"colorMapping"`
+	Database    map[string]string `json:"databaseMapping"`
+	Color       map[string]string `json:"colorMapping"`
+	ParentPage  string            `json:"parentPage"`
+	Single      *SingleDatabase   `json:"singleDatabase"`
+	Attachments *Attachments      `json:"attachments"`
+	// Emoji set as the icon of the pages imported from each list
+	ListIcons map[string]string `json:"listIcons"`
+	// Rules for the block each URL attachment becomes. Defaults to defaultEmbedRules when not set.
+	EmbedRules []*EmbedRule `json:"embedRules"`
+	Comments   *Comments    `json:"comments"`
+	// Maps Trello usernames or emails to the IDs of Notion users. "baleen users" lists the IDs of the Notion users.
+	Members map[string]string `json:"memberMapping"`
+	// Renames the properties that Trello custom fields are imported as. Fields that are not mapped keep their name.
+	CustomFields map[string]string `json:"customFieldMapping"`
+	// Fills a "Related Cards" relation with the cards that each card links to in the same database
+	RelatedCards bool `json:"relatedCards"`
+	// Database that archived cards are imported into instead of the database of their list
+	ArchivedDatabase string `json:"archivedDatabase"`
+}
+
+// How the comments on cards are imported
+type Comments struct {
+	// Either "callout" or "quote". Defaults to "callout".
+	Style string `json:"style"`
+	// Also posts the comments as Notion comments on the pages that are created. Notion shows them as posted by the
+	// integration, which needs the insert comments capability.
+	Post bool `json:"post"`
+}
+
+// Blocks that a URL attachment can become
+const (
+	BookmarkBlock = "bookmark"
+	EmbedBlock    = "embed"
+	VideoBlock    = "video"
+	LinkBlock     = "link"
+)
+
+// Turns URL attachments on a domain into a given block
+type EmbedRule struct {
+	// Domain that the rule matches along with its subdomains, such as "youtube.com"
+	Domain string `json:"domain"`
+	// One of "bookmark", "embed", "video" or "link"
+	Block string `json:"block"`
+}
+
+var defaultEmbedRules = []*EmbedRule{
+	{"youtube.com", VideoBlock},
+	{"youtu.be", VideoBlock},
+	{"vimeo.com", VideoBlock},
+	{"figma.com", EmbedBlock},
+	{"gist.github.com", EmbedBlock},
+	{"twitter.com", EmbedBlock},
+}
+
+// Uploads the files attached to cards instead of linking back to Trello, so that they survive the board being deleted
+type Attachments struct {
+	// Folder that downloaded attachments are cached in. Defaults to "data/attachments".
+	CacheDir string `json:"cacheDir"`
+	// Uploader that hosts the attachments for Notion to embed. Only "local" is available.
+	Uploader string `json:"uploader"`
+	// Folder the local uploader copies attachments into
+	LocalDir string `json:"localDir"`
+	// URL that the local uploader's folder is served at
+	LocalBaseUrl string `json:"localBaseUrl"`
+}
+
+func (attachments *Attachments) CacheFolder() string {
+	if attachments.CacheDir == "" {
+		return "data/attachments"
+	}
+
+	return attachments.CacheDir
+}
+
+// Imports every card into a single database instead of one database per list, with the list of each card stored in a
+// select property. Grouping a board view by the property gives the same columns as the Trello board. Notion's status
+// property cannot be created or set by the version of the Notion API used, so a select property is used instead.
+type SingleDatabase struct {
+	Name string `json:"name"`
+	// Name of the select property holding the list. Defaults to "List".
+	ListProperty string `json:"listProperty"`
+	// Renames lists to the option used in the select property. Lists that are not mapped keep their name.
+	ListMapping map[string]string `json:"listMapping"`
+}
+
+func (single *SingleDatabase) ListPropertyName() string {
+	if single.ListProperty == "" {
+		return "List"
+	}
+
+	return single.ListProperty
+}
+
+// ID of the Notion user that the Trello member is mapped to, looked up by username and then by email
+func (config *Config) NotionUserId(username, email string) (string, bool) {
+	if id, ok := config.Members[username]; ok && username != "" {
+		return id, true
+	}
+
+	if id, ok := config.Members[email]; ok && email != "" {
+		return id, true
+	}
+
+	return "", false
+}
+
+// Name of the property that the custom field is imported as
+func (config *Config) CustomFieldProperty(fieldName string) string {
+	if name, ok := config.CustomFields[fieldName]; ok && name != "" {
+		return name
+	}
+
+	return fieldName
+}
+
+// Whether comments are shown as quotes rather than callouts
+func (config *Config) QuoteComments() bool {
+	return config.Comments != nil && config.Comments.Style == "quote"
+}
+
+// Block that the URL attachment becomes. The first rule matching the URL's domain decides the block and URLs that no
+// rule matches become bookmarks.
+func (config *Config) UrlBlock(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return BookmarkBlock
+	}
+
+	rules := config.EmbedRules
+	if rules == nil {
+		rules = defaultEmbedRules
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, rule := range rules {
+		domain := strings.ToLower(rule.Domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return rule.Block
+		}
+	}
+
+	return BookmarkBlock
 }
 
 func New(configPath string) *Config {
@@ -30,9 +172,43 @@ func New(configPath string) *Config {
 func (config *Config) DatabaseNames() []string {
 	var names []string
 
-	for _, databaseMapping := range config.Database {
-		names = append(names, databaseMapping)
+	if config.Single != nil {
+		names = append(names, config.Single.Name)
+	} else {
+		for _, databaseMapping := range config.Database {
+			names = append(names, databaseMapping)
+		}
+	}
+
+	if config.ArchivedDatabase != "" {
+		names = append(names, config.ArchivedDatabase)
 	}
 
 	return names
 }
+
+// Name of the database that cards from the list are imported into
+func (config *Config) DatabaseName(listName string, archived bool) string {
+	if archived && config.ArchivedDatabase != "" {
+		return config.ArchivedDatabase
+	}
+
+	if config.Single != nil {
+		return config.Single.Name
+	}
+
+	return config.Database[listName]
+}
+
+// Name of the option that the list is stored as in a single database
+func (config *Config) ListOption(listName string) string {
+	if config.Single == nil {
+		return listName
+	}
+
+	if option, ok := config.Single.ListMapping[listName]; ok {
+		return option
+	}
+
+	return listName
+}
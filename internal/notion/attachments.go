@@ -0,0 +1,241 @@
+package notion
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/attachment"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/env"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+// Downloads the files uploaded to Trello cards and uploads them somewhere Notion can embed them from
+type attachmentPipeline struct {
+	cache    *attachment.Cache
+	uploader attachment.Uploader
+}
+
+// Returns nil when attachments are not configured, in which case files are linked back to Trello
+func newAttachmentPipeline(config *config.Config, env *env.Env) *attachmentPipeline {
+	if config.Attachments == nil {
+		return nil
+	}
+
+	cache, err := attachment.NewCache(config.Attachments.CacheFolder(), env.TrelloKey, env.TrelloToken)
+	if err != nil {
+		log.Fatalf("Failed to set up attachment cache: %v\n", err)
+	}
+
+	uploader, err := attachment.NewUploader(config.Attachments)
+	if err != nil {
+		log.Fatalf("Failed to set up attachment uploader: %v\n", err)
+	}
+
+	return &attachmentPipeline{cache, uploader}
+}
+
+// File uploaded to a card along with the URL that Notion loads it from
+type cardFile struct {
+	attachment *types.Attachment
+	url        string
+	mimeType   string
+	// Whether the file was uploaded by the pipeline rather than loaded from Trello
+	uploaded bool
+}
+
+// Resolves the files uploaded to the card. Without a pipeline, or when a file cannot be uploaded, the file is linked
+// back to Trello instead, as Trello only serves the file to members of the board.
+func (p *attachmentPipeline) files(card *types.Card) []*cardFile {
+	var files []*cardFile
+
+	for _, a := range card.Attachments {
+		if !a.IsUpload {
+			continue
+		}
+
+		file := &cardFile{attachment: a, url: a.Url, mimeType: attachmentMimeType(a)}
+
+		if p != nil {
+			url, mimeType, err := p.upload(a)
+			if err != nil {
+				log.Printf("Failed to upload attachment %s of card %s, linking to Trello instead: %v\n", a.Name, card.Name, err)
+			} else {
+				file.url, file.uploaded = url, true
+				if file.mimeType == "" {
+					file.mimeType = mimeType
+				}
+			}
+		}
+
+		files = append(files, file)
+	}
+
+	return files
+}
+
+// Blocks for the files uploaded to the card. Files that were not uploaded are linked back to Trello along with their
+// size, since Notion cannot load them from Trello to display them.
+func fileBlocks(files []*cardFile) []notionapi.Block {
+	var blocks []notionapi.Block
+
+	for _, file := range files {
+		if file.uploaded {
+			blocks = append(blocks, fileBlock(file.attachment.Name, file.url, file.mimeType))
+		} else {
+			blocks = append(blocks, linkBlock(fileLinkName(file.attachment), file.url))
+		}
+	}
+
+	return blocks
+}
+
+// Name of the link to a file on Trello, followed by the size of the file when Trello reported it
+func fileLinkName(a *types.Attachment) string {
+	if a.Bytes <= 0 {
+		return a.Name
+	}
+
+	size, units := float64(a.Bytes), []string{"B", "KB", "MB", "GB"}
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%s (%d B)", a.Name, a.Bytes)
+	}
+
+	return fmt.Sprintf("%s (%.1f %s)", a.Name, size, units[unit])
+}
+
+// Blocks for the links attached to the card, in the order they were attached. The embed rules in the config decide
+// whether a link is shown as a bookmark, an embed, a video or a plain link.
+func urlBlocks(config *config.Config, card *types.Card) []notionapi.Block {
+	var blocks []notionapi.Block
+
+	for _, a := range card.Attachments {
+		if a.IsUpload {
+			continue
+		}
+
+		// Notion rejects previews of anything that is not a web link
+		if !isValidLink(a.Url) || strings.HasPrefix(a.Url, "mailto:") {
+			blocks = append(blocks, linkBlock(a.Name, a.Url))
+			continue
+		}
+
+		caption := a.Name
+		if caption == a.Url {
+			caption = ""
+		}
+
+		blocks = append(blocks, urlBlock(config.UrlBlock(a.Url), a.Name, caption, a.Url))
+	}
+
+	return blocks
+}
+
+func urlBlock(block, name, caption, url string) notionapi.Block {
+	switch block {
+	case config.EmbedBlock:
+		return embedBlock(caption, url)
+	case config.VideoBlock:
+		return videoBlock(caption, url)
+	case config.LinkBlock:
+		return linkBlock(name, url)
+	default:
+		return bookmarkBlock(caption, url)
+	}
+}
+
+// Image set as the page cover. The card's cover is used when it is an image, otherwise the first image uploaded to
+// the card. Only images uploaded by the pipeline are used, as Notion cannot load images from Trello. Trello's color
+// covers have no equivalent in Notion.
+func pageCover(card *types.Card, files []*cardFile) *notionapi.Image {
+	var url string
+
+	if card.Cover != nil && card.Cover.AttachmentId != "" {
+		for _, file := range files {
+			if file.attachment.Id == card.Cover.AttachmentId && file.uploaded && isImage(file.mimeType) {
+				url = file.url
+				break
+			}
+		}
+	} else if card.Cover != nil {
+		url = card.Cover.Url
+	}
+
+	if url == "" {
+		for _, file := range files {
+			if file.uploaded && isImage(file.mimeType) {
+				url = file.url
+				break
+			}
+		}
+	}
+
+	if url == "" {
+		return nil
+	}
+
+	return &notionapi.Image{
+		Type:     "external",
+		External: &notionapi.FileObject{URL: url},
+	}
+}
+
+// Emoji icon configured for the card's list
+func pageIcon(config *config.Config, card *types.Card) *notionapi.Icon {
+	icon, ok := config.ListIcons[card.ParentListName]
+	if !ok || icon == "" {
+		return nil
+	}
+
+	emoji := notionapi.Emoji(icon)
+	return &notionapi.Icon{Type: "emoji", Emoji: &emoji}
+}
+
+func (p *attachmentPipeline) upload(a *types.Attachment) (string, string, error) {
+	file, err := p.cache.Fetch(a.Name, a.Url)
+	if err != nil {
+		return "", "", err
+	}
+
+	url, err := p.uploader.Upload(file)
+	if err != nil {
+		return "", "", err
+	}
+
+	return url, file.MimeType, nil
+}
+
+// MIME type reported by Trello, falling back to the one implied by the file extension for older attachments
+func attachmentMimeType(a *types.Attachment) string {
+	if a.MimeType != "" {
+		return a.MimeType
+	}
+
+	return mime.TypeByExtension(strings.ToLower(path.Ext(a.Name)))
+}
+
+func isImage(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+// Picks the block that Notion displays the file best in
+func fileBlock(name, url, mimeType string) notionapi.Block {
+	switch {
+	case isImage(mimeType):
+		return imageBlock(name, url)
+	case mimeType == "application/pdf":
+		return pdfBlock(name, url)
+	default:
+		return externalFileBlock(name, url)
+	}
+}
@@ -0,0 +1,44 @@
+package notion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+func TestFilesWithoutPipelineAreLinked(t *testing.T) {
+	card := &types.Card{
+		Cover: &types.Cover{AttachmentId: "1"},
+		Attachments: []*types.Attachment{
+			{Id: "1", IsUpload: true, Name: "photo.png", Url: "https://trello.com/1/photo.png", Bytes: 2560},
+			{Id: "2", IsUpload: true, Name: "notes.txt", Url: "https://trello.com/1/notes.txt", Bytes: 12},
+		},
+	}
+
+	var pipeline *attachmentPipeline
+	files := pipeline.files(card)
+
+	want := []string{"bulleted_list_item: [photo.png (2.5 KB)](https://trello.com/1/photo.png)", "bulleted_list_item: [notes.txt (12 B)](https://trello.com/1/notes.txt)"}
+	if got := describeBlocks(fileBlocks(files), ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("fileBlocks\n got: %q\nwant: %q", got, want)
+	}
+
+	if cover := pageCover(card, files); cover != nil {
+		t.Errorf("pageCover = %v, want no cover for an image on Trello", cover.External.URL)
+	}
+}
+
+func TestUploadedImagesAreShown(t *testing.T) {
+	photo := &types.Attachment{Id: "1", IsUpload: true, Name: "photo.png", Url: "https://trello.com/1/photo.png"}
+	card := &types.Card{Cover: &types.Cover{AttachmentId: "1"}, Attachments: []*types.Attachment{photo}}
+	files := []*cardFile{{attachment: photo, url: "https://cdn.example.com/photo.png", mimeType: "image/png", uploaded: true}}
+
+	if got := describeBlocks(fileBlocks(files), ""); !reflect.DeepEqual(got, []string{"image: "}) {
+		t.Errorf("fileBlocks = %q, want an image", got)
+	}
+
+	if cover := pageCover(card, files); cover == nil || cover.External.URL != "https://cdn.example.com/photo.png" {
+		t.Errorf("pageCover = %v, want the uploaded image", cover)
+	}
+}
@@ -0,0 +1,198 @@
+package notion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+const (
+	notionCommentsUrl = "https://api.notion.com/v1/comments"
+	// The comments API is only available from a newer version of the Notion API than the one the Notion client uses
+	commentsApiVersion = "2022-06-28"
+	commentIcon        = "💬"
+)
+
+// Blocks for the comments on a card, each a callout or quote holding who posted it and when, with the blocks of the
+// comment's Markdown nested under it
+func commentBlocks(config *config.Config, comments []*types.Comment) []notionapi.Block {
+	var blocks []notionapi.Block
+
+	for _, comment := range comments {
+		text := commentTitle(comment)
+		children := flattenListItems(markdownBlocks(comment.Text))
+
+		if config.QuoteComments() {
+			blocks = append(blocks, notionapi.QuoteBlock{
+				BasicBlock: basicBlock(notionapi.BlockQuote),
+				Quote:      notionapi.Quote{Text: text, Children: children},
+			})
+			continue
+		}
+
+		emoji := notionapi.Emoji(commentIcon)
+		blocks = append(blocks, notionapi.CalloutBlock{
+			BasicBlock: basicBlock(notionapi.BlockCallout),
+			Callout: notionapi.Callout{
+				Text:     text,
+				Icon:     &notionapi.Icon{Type: "emoji", Emoji: &emoji},
+				Children: children,
+			},
+		})
+	}
+
+	return blocks
+}
+
+// Header of a comment in bold followed by its reactions. Comments from saves that did not keep their author have no
+// header.
+func commentTitle(comment *types.Comment) []notionapi.RichText {
+	text := []notionapi.RichText{}
+	if header := commentHeader(comment); header != "" {
+		text = append(text, inlineStyle{bold: true}.richText(header)...)
+	}
+
+	if reactions := commentReactions(comment); reactions != "" {
+		if len(text) > 0 {
+			reactions = "\n" + reactions
+		}
+		text = append(text, inlineStyle{}.richText(reactions)...)
+	}
+
+	return text
+}
+
+// Rich text of a comment posted through the comments API, which only takes rich text, so the comment's Markdown is
+// kept inline under its header
+func commentText(comment *types.Comment) []notionapi.RichText {
+	title := commentTitle(comment)
+	if len(title) == 0 {
+		return markdownRichText(comment.Text)
+	}
+
+	text := append(title, inlineStyle{}.richText("\n")...)
+	return append(text, markdownRichText(comment.Text)...)
+}
+
+// Reactions to the comment as each emoji followed by how many members reacted with it
+func commentReactions(comment *types.Comment) string {
+	var reactions []string
+	for _, reaction := range comment.Reactions {
+		reactions = append(reactions, fmt.Sprintf("%s %d", reaction.Emoji, reaction.Count))
+	}
+
+	return strings.Join(reactions, "  ")
+}
+
+// Moves the items nested in list items up to follow their parent. Notion only takes two levels of blocks in a request,
+// and the comment's blocks are already nested under the comment.
+func flattenListItems(blocks []notionapi.Block) []notionapi.Block {
+	var flattened []notionapi.Block
+	for _, block := range blocks {
+		var children []notionapi.Block
+		switch b := block.(type) {
+		case notionapi.BulletedListItemBlock:
+			children, b.BulletedListItem.Children = b.BulletedListItem.Children, []notionapi.Block{}
+			block = b
+		case notionapi.NumberedListItemBlock:
+			children, b.NumberedListItem.Children = b.NumberedListItem.Children, []notionapi.Block{}
+			block = b
+		}
+
+		flattened = append(flattened, block)
+		flattened = append(flattened, children...)
+	}
+
+	return flattened
+}
+
+func commentHeader(comment *types.Comment) string {
+	var parts []string
+
+	switch {
+	case comment.Author != "" && comment.Username != "":
+		parts = append(parts, fmt.Sprintf("%s (@%s)", comment.Author, comment.Username))
+	case comment.Author != "":
+		parts = append(parts, comment.Author)
+	case comment.Username != "":
+		parts = append(parts, "@"+comment.Username)
+	}
+
+	if comment.Date != nil {
+		parts = append(parts, comment.Date.Format("2006-01-02 15:04 MST"))
+	}
+
+	header := strings.Join(parts, " · ")
+	if comment.Edited && header != "" {
+		header += " (edited)"
+	}
+
+	return header
+}
+
+// Posts comments to pages through the Notion comments API, which the Notion client does not support
+type commentPoster struct {
+	client *http.Client
+	token  string
+}
+
+// Returns nil when comments are not set to be posted
+func newCommentPoster(config *config.Config, client *http.Client, token string) *commentPoster {
+	if config.Comments == nil || !config.Comments.Post {
+		return nil
+	}
+
+	return &commentPoster{client, token}
+}
+
+type commentRequest struct {
+	Parent   notionapi.Parent     `json:"parent"`
+	RichText []notionapi.RichText `json:"rich_text"`
+}
+
+// Posts the comments to the page in order. Notion comments cannot be edited or removed through the API, so this is
+// only done for pages that were just created. Safe to call on a nil poster, which posts nothing.
+func (p *commentPoster) post(page notionapi.PageID, comments []*types.Comment) error {
+	if p == nil {
+		return nil
+	}
+
+	for _, comment := range comments {
+		body, err := json.Marshal(commentRequest{
+			Parent:   notionapi.Parent{Type: "page_id", PageID: page},
+			RichText: commentText(comment),
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, notionCommentsUrl, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+p.token)
+		req.Header.Set("Notion-Version", commentsApiVersion)
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := p.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		message, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to post comment: %s %s", res.Status, string(message))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package notion
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+func TestCommentBlocks(t *testing.T) {
+	date := time.Date(2022, 3, 4, 5, 6, 0, 0, time.UTC)
+	comments := []*types.Comment{{
+		Author:    "Alex Tan",
+		Username:  "alextan",
+		Date:      &date,
+		Text:      "# Plan\n- one\n  - nested\n\n**done**",
+		Reactions: []*types.Reaction{{Emoji: "👍", Count: 2}, {Emoji: "🎉", Count: 1}},
+	}}
+
+	tests := []struct {
+		name   string
+		config *config.Config
+		want   []string
+	}{
+		{
+			name:   "callout",
+			config: &config.Config{},
+			want: []string{
+				"callout: **Alex Tan (@alextan) · 2022-03-04 05:06 UTC**\n👍 2  🎉 1",
+				"  heading_1: Plan",
+				"  bulleted_list_item: one",
+				"  bulleted_list_item: nested",
+				"  paragraph: **done**",
+			},
+		},
+		{
+			name:   "quote",
+			config: &config.Config{Comments: &config.Comments{Style: "quote"}},
+			want: []string{
+				"quote: **Alex Tan (@alextan) · 2022-03-04 05:06 UTC**\n👍 2  🎉 1",
+				"  heading_1: Plan",
+				"  bulleted_list_item: one",
+				"  bulleted_list_item: nested",
+				"  paragraph: **done**",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := describeBlocks(commentBlocks(test.config, comments), "")
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("commentBlocks\n got: %q\nwant: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommentTextWithoutAuthor(t *testing.T) {
+	comment := &types.Comment{Text: "just **text**"}
+
+	if got := describeRichText(commentText(comment)); got != "just **text**" {
+		t.Errorf("commentText = %q, want the comment's text alone", got)
+	}
+	if got := commentTitle(comment); got == nil || len(got) != 0 {
+		t.Errorf("commentTitle = %v, want empty rich text", got)
+	}
+}
@@ -0,0 +1,149 @@
+package notion
+
+import (
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+// Custom field found on the cards along with the options picked for it when it is a list field
+type customFieldSchema struct {
+	name      string
+	fieldType string
+	options   []*types.Label
+}
+
+// Adds the custom fields set on the card to the unique custom fields
+func extractCustomFields(fields []*customFieldSchema, card *types.Card) []*customFieldSchema {
+	for _, value := range card.CustomFields {
+		field := findCustomField(value.Name, fields)
+		if field == nil {
+			field = &customFieldSchema{name: value.Name, fieldType: value.Type}
+			fields = append(fields, field)
+		}
+
+		if value.Type == "list" && value.Text != "" && findLabel(value.Text, field.options) == nil {
+			field.options = append(field.options, &types.Label{Name: value.Text, Color: value.Color})
+		}
+	}
+
+	return fields
+}
+
+// Adds the custom fields defined on the board to the unique custom fields, so that fields and options that no card has
+// picked are still in the schema
+func extractCustomFieldDefinitions(
+	fields []*customFieldSchema,
+	definitions []*types.CustomFieldDefinition,
+) []*customFieldSchema {
+	for _, definition := range definitions {
+		field := findCustomField(definition.Name, fields)
+		if field == nil {
+			field = &customFieldSchema{name: definition.Name, fieldType: definition.Type}
+			fields = append(fields, field)
+		}
+
+		for _, option := range definition.Options {
+			if option.Name != "" && findLabel(option.Name, field.options) == nil {
+				field.options = append(field.options, &types.Label{Name: option.Name, Color: option.Color})
+			}
+		}
+	}
+
+	return fields
+}
+
+func findCustomField(name string, fields []*customFieldSchema) *customFieldSchema {
+	for _, field := range fields {
+		if field.name == name {
+			return field
+		}
+	}
+
+	return nil
+}
+
+func findLabel(name string, labels []*types.Label) *types.Label {
+	for _, label := range labels {
+		if label.Name == name {
+			return label
+		}
+	}
+
+	return nil
+}
+
+// Adds a property for every custom field, typed after the field
+func addCustomFieldConfigs(config *config.Config, properties notionapi.PropertyConfigs, fields []*customFieldSchema) {
+	for _, field := range fields {
+		name := customFieldProperty(config, field.name)
+
+		switch field.fieldType {
+		case "list":
+			properties[name] = selectConfig(organizeLabels(config, field.options))
+		case "number":
+			properties[name] = numberConfig()
+		case "date":
+			properties[name] = dateConfig()
+		case "checkbox":
+			properties[name] = checkboxConfig()
+		case "text":
+			properties[name] = richTextConfig()
+		}
+	}
+}
+
+// Sets the property of every custom field set on the card
+func addCustomFieldProperties(config *config.Config, properties notionapi.Properties, card *types.Card) {
+	for _, value := range card.CustomFields {
+		name := customFieldProperty(config, value.Name)
+
+		switch value.Type {
+		case "list":
+			if value.Text != "" {
+				properties[name] = selectProperty(value.Text)
+			}
+		case "number":
+			properties[name] = numberProperty(value.Number)
+		case "date":
+			if value.Date != nil {
+				properties[name] = dateProperty(value.Date)
+			}
+		case "checkbox":
+			properties[name] = checkboxProperty(value.Checked)
+		case "text":
+			properties[name] = richTextProperty(value.Text, noLink)
+		}
+	}
+}
+
+// Name of the property that the custom field is imported as. Fields that would replace one of the properties baleen
+// imports into are suffixed instead.
+func customFieldProperty(config *config.Config, fieldName string) string {
+	name := config.CustomFieldProperty(fieldName)
+
+	reserved := []string{
+		"Name",
+		cardIdProperty,
+		shortLinkProperty,
+		"Description",
+		"Primary Link",
+		"Labels",
+		"Last Updated",
+		"Due",
+		"Done",
+		"Archived",
+		assigneesProperty,
+		unmappedAssigneesProperty,
+		relatedCardsProperty,
+	}
+	if config.Single != nil {
+		reserved = append(reserved, config.Single.ListPropertyName())
+	}
+
+	if contains(name, reserved) {
+		return name + " (Trello)"
+	}
+
+	return name
+}
@@ -0,0 +1,216 @@
+package notion
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jomei/notionapi"
+)
+
+// Entry in the checkpoint journal recording that a card has been imported as a Notion page
+type journalEntry struct {
+	CardId string
+	PageId string
+	// Set when the page was created but the import of the card failed part way, so that the card is imported again
+	// into the same page rather than into a new one
+	Partial    bool   `json:",omitempty"`
+	DatabaseId string `json:",omitempty"`
+}
+
+// Checkpoint journal of the cards imported into Notion. Every entry is written to disk as soon as its card lands so
+// that an interrupted import can be resumed without importing the same cards again.
+type journal struct {
+	mu       sync.Mutex
+	file     *os.File
+	imported map[cardId]notionapi.PageID
+	// Pages created for cards that failed to import part way, which are not counted as imported
+	partial map[cardId]existingPage
+}
+
+func newJournal() *journal {
+	return &journal{imported: make(map[cardId]notionapi.PageID), partial: make(map[cardId]existingPage)}
+}
+
+// Path of the journal kept next to a save file
+func JournalPath(savePath string) string {
+	return savePath + ".journal"
+}
+
+// Opens the journal at journalPath. When resuming, the cards recorded by the existing journal are loaded and new entries
+// are appended to it, otherwise the journal is started from scratch. An entry cut short by an import that was killed
+// while writing it is dropped before appending, so that the next entry starts on a line of its own.
+func openJournal(journalPath string, resume bool) *journal {
+	j := newJournal()
+
+	if err := os.MkdirAll(filepath.Dir(journalPath), 0755); err != nil {
+		log.Fatalf("Failed to create the folder of journal %s: %v\n", journalPath, err)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if complete, size := j.load(journalPath); complete < size {
+			log.Printf("Dropping the incomplete last entry of journal %s\n", journalPath)
+			if err := os.Truncate(journalPath, complete); err != nil {
+				log.Fatalf("Failed to repair journal %s: %v\n", journalPath, err)
+			}
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(journalPath, flags, 0644)
+	if err != nil {
+		log.Fatalf("Failed to open journal %s: %v\n", journalPath, err)
+	}
+	j.file = file
+
+	return j
+}
+
+// Reads the journal at journalPath without opening it for writing. Used by dry runs, which must not touch the journal.
+func readJournal(journalPath string) *journal {
+	j := newJournal()
+	j.load(journalPath)
+
+	return j
+}
+
+// Loads the entries of the journal at journalPath. Returns the size of the journal up to the end of its last complete
+// line along with its full size, which differ when the last entry was cut short.
+func (j *journal) load(journalPath string) (complete, size int64) {
+	file, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		log.Printf("No journal found at %s, importing all cards\n", journalPath)
+		return 0, 0
+	}
+	if err != nil {
+		log.Fatalf("Failed to read journal %s: %v\n", journalPath, err)
+	}
+
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		size += int64(len(line))
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatalf("Failed to read journal %s: %v\n", journalPath, err)
+		}
+
+		complete = size
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+
+		if entry.Partial {
+			j.partial[cardId(entry.CardId)] = existingPage{
+				id:       notionapi.PageID(entry.PageId),
+				database: databaseId(entry.DatabaseId),
+			}
+		} else {
+			j.imported[cardId(entry.CardId)] = notionapi.PageID(entry.PageId)
+			delete(j.partial, cardId(entry.CardId))
+		}
+	}
+
+	log.Printf("Loaded %d imported cards from journal %s\n", len(j.imported), journalPath)
+
+	return complete, size
+}
+
+func (j *journal) isImported(id string) bool {
+	if j == nil {
+		return false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, ok := j.imported[cardId(id)]
+	return ok
+}
+
+// Page that the journal recorded the card as imported into
+func (j *journal) page(id string) (notionapi.PageID, bool) {
+	if j == nil {
+		return "", false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	page, ok := j.imported[cardId(id)]
+	return page, ok
+}
+
+// Page created for the card by an import that failed part way
+func (j *journal) partialPage(id string) (existingPage, bool) {
+	if j == nil {
+		return existingPage{}, false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	page, ok := j.partial[cardId(id)]
+	return page, ok
+}
+
+// Records that the card has been imported as the page. Safe to call on a nil journal, which records nothing.
+func (j *journal) record(id string, page notionapi.PageID) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.write(journalEntry{CardId: id, PageId: string(page)})
+	j.imported[cardId(id)] = page
+	delete(j.partial, cardId(id))
+}
+
+// Records that the page was created for the card but the card failed to import, so that importing the card again
+// finishes that page instead of creating another one. Safe to call on a nil journal, which records nothing.
+func (j *journal) recordPartial(id string, page existingPage) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.write(journalEntry{CardId: id, PageId: string(page.id), Partial: true, DatabaseId: string(page.database)})
+	j.partial[cardId(id)] = page
+}
+
+func (j *journal) write(entry journalEntry) {
+	line, _ := json.Marshal(entry)
+	line = append(line, '\n')
+
+	if _, err := j.file.Write(line); err != nil {
+		log.Fatalf("Failed to write to journal: %v\n", err)
+	}
+
+	if err := j.file.Sync(); err != nil {
+		log.Fatalf("Failed to write to journal: %v\n", err)
+	}
+}
+
+func (j *journal) close() {
+	if j == nil {
+		return
+	}
+
+	j.file.Close()
+}
@@ -0,0 +1,81 @@
+package notion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+func TestJournalDropsIncompleteEntryOnResume(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "save.ndjson.journal")
+	contents := `{"CardId":"a","PageId":"page-a"}` + "\n" + `{"CardId":"b","Pa`
+	if err := os.WriteFile(journalPath, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := openJournal(journalPath, true)
+	if !j.isImported("a") || j.isImported("b") {
+		t.Fatalf("imported = %v, want only card a", j.imported)
+	}
+	j.record("c", "page-c")
+	j.close()
+
+	resumed := readJournal(journalPath)
+	for id, page := range map[string]notionapi.PageID{"a": "page-a", "c": "page-c"} {
+		if got, ok := resumed.page(id); !ok || got != page {
+			t.Errorf("page(%s) = %s, %v, want %s", id, got, ok, page)
+		}
+	}
+}
+
+func TestReadJournalLeavesIncompleteEntry(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "save.ndjson.journal")
+	contents := `{"CardId":"a","PageId":"page-a"}` + "\n" + `{"CardId":"b","Pa`
+	if err := os.WriteFile(journalPath, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if j := readJournal(journalPath); !j.isImported("a") {
+		t.Errorf("card a was not loaded")
+	}
+
+	data, _ := os.ReadFile(journalPath)
+	if string(data) != contents {
+		t.Errorf("dry runs must not change the journal, got %q", data)
+	}
+}
+
+func TestJournalKeepsPagesOfPartialImports(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "save.ndjson.journal")
+
+	j := openJournal(journalPath, false)
+	j.recordPartial("a", existingPage{id: "page-a", database: "database"})
+	j.recordPartial("b", existingPage{id: "page-b", database: "database"})
+	j.record("b", "page-b")
+	j.close()
+
+	resumed := readJournal(journalPath)
+	if resumed.isImported("a") {
+		t.Errorf("card a failed to import but is counted as imported")
+	}
+	if page, ok := resumed.partialPage("a"); !ok || page != (existingPage{id: "page-a", database: "database"}) {
+		t.Errorf("partialPage(a) = %v, %v, want the page created for it", page, ok)
+	}
+	if _, ok := resumed.partialPage("b"); ok || !resumed.isImported("b") {
+		t.Errorf("card b was imported after failing, want it counted as imported")
+	}
+}
+
+func TestOpenJournalCreatesItsFolder(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "journals", "board.journal")
+
+	j := openJournal(journalPath, true)
+	j.record("a", "page-a")
+	j.close()
+
+	if !readJournal(journalPath).isImported("a") {
+		t.Errorf("card a was not recorded")
+	}
+}
@@ -0,0 +1,455 @@
+package notion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/types"
+	"golang.org/x/net/context"
+)
+
+// Name of the self relation holding the cards that a card links to
+const relatedCardsProperty = "Related Cards"
+
+const notionBlocksUrl = "https://api.notion.com/v1/blocks/"
+
+// Links to a Trello card, capturing the card's short link
+var trelloCardLink = regexp.MustCompile(`https?://(?:www\.)?trello\.com/c/([A-Za-z0-9]+)(?:/[^\s)\]>"']*)?`)
+
+// Pages that cards were imported as, keyed by the short link of the card
+type cardLinks map[string]existingPage
+
+// Rewrites the links between cards to point at the pages they were imported as. This is a second pass over the cards
+// that link to other cards, as a card can link to a card that is only imported after it. Links can point at any page
+// already in the databases, so that cards imported by an incremental migration link to the cards imported before them.
+// Only the properties and the blocks holding links to other cards are updated, leaving the rest of the page as it was
+// imported. Links in the blocks become mentions of the pages, while the properties, bookmarks and embeds link to the
+// pages by their URL.
+func linkCards(
+	config *config.Config,
+	notion *notionapi.Client,
+	patcher *blockPatcher,
+	nameIds *databaseNameIds,
+	existing *existingPages,
+	pages map[cardId]notionapi.PageID,
+	cards types.CardStream,
+	concurrency int,
+) {
+	links, unknown := existingLinks(existing, pages)
+	eachCard(cards, func(card *types.Card) {
+		if id, ok := pages[cardId(card.Id)]; ok && card.ShortLink != "" {
+			database := (*nameIds)[databaseName(config.DatabaseName(card.ParentListName, card.Archived))]
+			links[card.ShortLink] = existingPage{id, database, card.ShortLink}
+		}
+	})
+
+	linking := types.FilterCards(cards, func(card *types.Card) bool {
+		_, ok := pages[cardId(card.Id)]
+		return ok && len(links.linked(card)) > 0
+	})
+
+	total := 0
+	eachCard(linking, func(*types.Card) { total++ })
+	if total == 0 {
+		return
+	}
+
+	log.Printf("Linking %d cards to the cards they mention\n", total)
+
+	// Links to the pages without a short link cannot be found, so the related cards found are only added to the ones
+	// already on the page instead of replacing them
+	if unknown > 0 && config.RelatedCards {
+		log.Printf(
+			"%d pages were imported before their short links were stored, so related cards are only added to. "+
+				"Migrate without --incremental to store every short link\n",
+			unknown,
+		)
+	}
+
+	failed := 0
+	err := types.ProcessCardStream(linking, concurrency, func(card *types.Card) error {
+		id := pages[cardId(card.Id)]
+
+		// The pipeline is left out as only the properties of the page are needed
+		built := buildPage(config, nameIds, nil, links.rewrite(card))
+		if config.RelatedCards {
+			related := links.related(card, built.database)
+			if unknown > 0 {
+				current, err := pageRelation(notion, id, relatedCardsProperty)
+				if err != nil {
+					return err
+				}
+				related = mergePageIds(current, related)
+			}
+			built.properties[relatedCardsProperty] = relationProperty(related)
+		}
+
+		_, err := notion.Page.Update(context.Background(), id, &notionapi.PageUpdateRequest{Properties: built.properties})
+		if err != nil {
+			return err
+		}
+
+		return links.relinkBlocks(notion, patcher, notionapi.BlockID(id))
+	}, func(result types.CardResult) {
+		if result.Err != nil {
+			log.Printf("Failed to link card %s: %v\n", result.Card.Name, result.Err)
+			failed++
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to read cards: %v\n", err)
+	}
+
+	log.Printf("Linked %d/%d cards\n", total-failed, total)
+}
+
+// Pages already in the databases, keyed by their short link. Also returns the number of those pages that have no short
+// link stored and are not being imported again, which links cannot be found to.
+func existingLinks(existing *existingPages, pages map[cardId]notionapi.PageID) (cardLinks, int) {
+	links := make(cardLinks)
+	unknown := 0
+
+	for id, page := range *existing {
+		if page.shortLink != "" {
+			links[page.shortLink] = page
+		} else if _, ok := pages[id]; !ok {
+			unknown++
+		}
+	}
+
+	return links, unknown
+}
+
+// Pages that the relation property of the page points at
+func pageRelation(notion *notionapi.Client, id notionapi.PageID, name string) ([]notionapi.PageID, error) {
+	page, err := notion.Page.Get(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []notionapi.PageID
+	if property, ok := page.Properties[name].(*notionapi.RelationProperty); ok {
+		for _, relation := range property.Relation {
+			ids = append(ids, relation.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// Page IDs from both lists without repeats, keeping the order they first appear in
+func mergePageIds(first, second []notionapi.PageID) []notionapi.PageID {
+	var merged []notionapi.PageID
+	seen := make(map[string]bool)
+
+	for _, id := range append(append([]notionapi.PageID{}, first...), second...) {
+		key := strings.ReplaceAll(string(id), "-", "")
+		if !seen[key] {
+			seen[key] = true
+			merged = append(merged, id)
+		}
+	}
+
+	return merged
+}
+
+// Points the links to imported cards in the blocks under the block at their pages, going through nested blocks such as
+// the Markdown of comments. Blocks without such links are left untouched.
+func (links cardLinks) relinkBlocks(notion *notionapi.Client, patcher *blockPatcher, id notionapi.BlockID) error {
+	var cursor notionapi.Cursor
+	for {
+		resp, err := notion.Block.GetChildren(context.Background(), id, &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    100,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, block := range resp.Results {
+			if err := links.relinkBlock(notion, patcher, block); err != nil {
+				return err
+			}
+
+			if block.GetHasChildren() {
+				if err := links.relinkBlocks(notion, patcher, block.GetID()); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !resp.HasMore {
+			return nil
+		}
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+}
+
+// Updates the block when it holds links to imported cards. Links in the text of the block become mentions of the pages,
+// while bookmarks and embeds can only hold a URL and so link to the pages instead.
+func (links cardLinks) relinkBlock(notion *notionapi.Client, patcher *blockPatcher, block notionapi.Block) error {
+	var texts []notionapi.RichText
+
+	switch b := block.(type) {
+	case *notionapi.ParagraphBlock:
+		texts = b.Paragraph.Text
+	case *notionapi.Heading1Block:
+		texts = b.Heading1.Text
+	case *notionapi.Heading2Block:
+		texts = b.Heading2.Text
+	case *notionapi.Heading3Block:
+		texts = b.Heading3.Text
+	case *notionapi.BulletedListItemBlock:
+		texts = b.BulletedListItem.Text
+	case *notionapi.NumberedListItemBlock:
+		texts = b.NumberedListItem.Text
+	case *notionapi.ToDoBlock:
+		texts = b.ToDo.Text
+	case *notionapi.QuoteBlock:
+		texts = b.Quote.Text
+	case *notionapi.CalloutBlock:
+		texts = b.Callout.Text
+	case *notionapi.BookmarkBlock:
+		if url := links.rewriteText(b.Bookmark.URL); url != b.Bookmark.URL {
+			request := &notionapi.BlockUpdateRequest{Bookmark: &notionapi.Bookmark{Caption: b.Bookmark.Caption, URL: url}}
+			_, err := notion.Block.Update(context.Background(), block.GetID(), request)
+			return err
+		}
+		return nil
+	case *notionapi.EmbedBlock:
+		if url := links.rewriteText(b.Embed.URL); url != b.Embed.URL {
+			request := &notionapi.BlockUpdateRequest{Embed: &notionapi.Embed{Caption: b.Embed.Caption, URL: url}}
+			_, err := notion.Block.Update(context.Background(), block.GetID(), request)
+			return err
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if text, ok := links.rewriteRichText(texts); ok {
+		return patcher.patchText(block.GetID(), block.GetType(), text)
+	}
+
+	return nil
+}
+
+// Rich text with its links to imported cards turned into mentions of their pages, for sending back to Notion. A link
+// to a card is replaced by the mention as a whole, as a mention always shows the title of the page. Returns false when
+// nothing links to an imported card, or when the text already holds mentions or equations, which the Notion client
+// cannot read back.
+func (links cardLinks) rewriteRichText(texts []notionapi.RichText) ([]blockText, bool) {
+	var rewritten []blockText
+	changed := false
+
+	for _, text := range texts {
+		if text.Type != "" && text.Type != notionapi.ObjectTypeText {
+			return nil, false
+		}
+
+		if text.Text.Link != nil {
+			if page, ok := links.page(text.Text.Link.Url); ok {
+				rewritten = append(rewritten, mentionText(page.id, text.Annotations))
+				changed = true
+				continue
+			}
+		}
+
+		content := text.Text.Content
+		last := 0
+		for _, match := range trelloCardLink.FindAllStringSubmatchIndex(content, -1) {
+			page, ok := links[content[match[2]:match[3]]]
+			if !ok {
+				continue
+			}
+
+			if match[0] > last {
+				rewritten = append(rewritten, plainText(content[last:match[0]], text.Text.Link, text.Annotations))
+			}
+			rewritten = append(rewritten, mentionText(page.id, text.Annotations))
+			last = match[1]
+			changed = true
+		}
+
+		if last == 0 || last < len(content) {
+			rewritten = append(rewritten, plainText(content[last:], text.Text.Link, text.Annotations))
+		}
+	}
+
+	return rewritten, changed
+}
+
+// Page of the imported card that the URL links to
+func (links cardLinks) page(url string) (existingPage, bool) {
+	match := trelloCardLink.FindStringSubmatch(url)
+	if match == nil || match[0] != url {
+		return existingPage{}, false
+	}
+
+	page, ok := links[match[1]]
+	return page, ok
+}
+
+// Short links of the imported cards that the card links to, in the order they first appear
+func (links cardLinks) linked(card *types.Card) []string {
+	texts := []string{card.Description}
+	for _, comment := range card.Comments {
+		texts = append(texts, comment.Text)
+	}
+	for _, attachment := range card.Attachments {
+		texts = append(texts, attachment.Url)
+	}
+
+	var shortLinks []string
+	for _, text := range texts {
+		for _, match := range trelloCardLink.FindAllStringSubmatch(text, -1) {
+			shortLink := match[1]
+			if _, ok := links[shortLink]; ok && shortLink != card.ShortLink && !contains(shortLink, shortLinks) {
+				shortLinks = append(shortLinks, shortLink)
+			}
+		}
+	}
+
+	return shortLinks
+}
+
+// Pages in the database that the card links to. A relation can only point at pages in a single database.
+func (links cardLinks) related(card *types.Card, database databaseId) []notionapi.PageID {
+	var ids []notionapi.PageID
+	for _, shortLink := range links.linked(card) {
+		if page := links[shortLink]; page.database == database {
+			ids = append(ids, page.id)
+		}
+	}
+
+	return ids
+}
+
+// Copy of the card with its links to imported cards pointing at their pages instead
+func (links cardLinks) rewrite(card *types.Card) *types.Card {
+	rewritten := *card
+	rewritten.Description = links.rewriteText(card.Description)
+
+	rewritten.Comments = nil
+	for _, comment := range card.Comments {
+		c := *comment
+		c.Text = links.rewriteText(comment.Text)
+		rewritten.Comments = append(rewritten.Comments, &c)
+	}
+
+	rewritten.Attachments = nil
+	for _, attachment := range card.Attachments {
+		a := *attachment
+		if !a.IsUpload {
+			a.Url = links.rewriteText(attachment.Url)
+			if attachment.Name == attachment.Url {
+				a.Name = a.Url
+			}
+		}
+		rewritten.Attachments = append(rewritten.Attachments, &a)
+	}
+
+	return &rewritten
+}
+
+func (links cardLinks) rewriteText(text string) string {
+	return trelloCardLink.ReplaceAllStringFunc(text, func(link string) string {
+		page, ok := links[trelloCardLink.FindStringSubmatch(link)[1]]
+		if !ok {
+			return link
+		}
+
+		return pageUrl(page.id)
+	})
+}
+
+func pageUrl(id notionapi.PageID) string {
+	return "https://www.notion.so/" + strings.ReplaceAll(string(id), "-", "")
+}
+
+// Version of the Notion API that the Notion client uses, in which blocks hold their rich text as text
+const blocksApiVersion = "2021-08-16"
+
+// Rich text as the blocks endpoint takes it. The rich text of the Notion client cannot hold mentions.
+type blockText struct {
+	Type        notionapi.ObjectType   `json:"type"`
+	Text        *notionapi.Text        `json:"text,omitempty"`
+	Mention     *pageMention           `json:"mention,omitempty"`
+	Annotations *notionapi.Annotations `json:"annotations,omitempty"`
+}
+
+type pageMention struct {
+	Type string        `json:"type"`
+	Page pageReference `json:"page"`
+}
+
+type pageReference struct {
+	Id notionapi.PageID `json:"id"`
+}
+
+func plainText(content string, link *notionapi.Link, annotations *notionapi.Annotations) blockText {
+	return blockText{
+		Type:        notionapi.ObjectTypeText,
+		Text:        &notionapi.Text{Content: content, Link: link},
+		Annotations: annotations,
+	}
+}
+
+func mentionText(id notionapi.PageID, annotations *notionapi.Annotations) blockText {
+	return blockText{
+		Type:        "mention",
+		Mention:     &pageMention{Type: "page", Page: pageReference{id}},
+		Annotations: annotations,
+	}
+}
+
+// Updates the text of blocks through the blocks endpoint, as the Notion client can neither write mentions nor update
+// quote and callout blocks
+type blockPatcher struct {
+	client *http.Client
+	token  string
+}
+
+func (p *blockPatcher) patchText(id notionapi.BlockID, blockType notionapi.BlockType, text []blockText) error {
+	body, err := textPatch(blockType, text)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, notionBlocksUrl+id.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Notion-Version", blocksApiVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	message, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update block: %s %s", res.Status, string(message))
+	}
+
+	return nil
+}
+
+// Body of the request that replaces the text of a block
+func textPatch(blockType notionapi.BlockType, text []blockText) ([]byte, error) {
+	return json.Marshal(map[notionapi.BlockType]interface{}{
+		blockType: map[string][]blockText{"text": text},
+	})
+}
@@ -0,0 +1,145 @@
+package notion
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	na "github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+func TestRewriteRichText(t *testing.T) {
+	links := cardLinks{"abc123": existingPage{id: "11111111-2222-3333-4444-555555555555"}}
+	mention := `{"type":"mention","mention":{"type":"page","page":{"id":"11111111-2222-3333-4444-555555555555"}}}`
+	bold := &na.Annotations{Bold: true}
+
+	tests := []struct {
+		name    string
+		texts   []na.RichText
+		want    string
+		changed bool
+	}{
+		{
+			name:    "bare link",
+			texts:   richText("https://trello.com/c/abc123/4-name", "https://trello.com/c/abc123/4-name"),
+			want:    "[" + mention + "]",
+			changed: true,
+		},
+		{
+			name:    "link in text",
+			texts:   []na.RichText{{Type: na.ObjectTypeText, Text: na.Text{Content: "see https://trello.com/c/abc123 first"}}},
+			want:    `[{"type":"text","text":{"content":"see "}},` + mention + `,{"type":"text","text":{"content":" first"}}]`,
+			changed: true,
+		},
+		{
+			name:    "formatting is kept",
+			texts:   []na.RichText{{Type: na.ObjectTypeText, Text: na.Text{Content: "https://trello.com/c/abc123"}, Annotations: bold}},
+			want:    `[{"type":"mention","mention":{"type":"page","page":{"id":"11111111-2222-3333-4444-555555555555"}},"annotations":{"bold":true,"italic":false,"strikethrough":false,"underline":false,"code":false,"color":"default"}}]`,
+			changed: true,
+		},
+		{
+			name:  "link to a card that was not imported",
+			texts: richText("https://trello.com/c/zzz999", noLink),
+		},
+		{
+			name:  "mentions are left alone",
+			texts: []na.RichText{{Type: "mention"}, {Type: na.ObjectTypeText, Text: na.Text{Content: "https://trello.com/c/abc123"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed := links.rewriteRichText(test.texts)
+			if changed != test.changed {
+				t.Fatalf("changed = %v, want %v", changed, test.changed)
+			}
+			if !changed {
+				return
+			}
+
+			body, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != test.want {
+				t.Errorf("rewriteRichText\n got: %s\nwant: %s", body, test.want)
+			}
+		})
+	}
+}
+
+func TestTextPatchHoldsMentions(t *testing.T) {
+	links := cardLinks{"abc123": existingPage{id: "11111111-2222-3333-4444-555555555555"}}
+	text, _ := links.rewriteRichText(richText("https://trello.com/c/abc123", noLink))
+
+	body, err := textPatch(na.BlockQuote, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"quote": map[string]interface{}{
+			"text": []interface{}{
+				map[string]interface{}{
+					"type": "mention",
+					"mention": map[string]interface{}{
+						"type": "page",
+						"page": map[string]interface{}{"id": "11111111-2222-3333-4444-555555555555"},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("textPatch = %s, want a page mention under quote.text", body)
+	}
+}
+
+func TestPlanLinks(t *testing.T) {
+	existing := existingPages{"3": {id: "page-3", shortLink: "ccc"}, "4": {id: "page-4"}}
+	cards := types.CardSlice{
+		{Id: "1", Name: "One", ShortLink: "aaa", Description: "after https://trello.com/c/bbb and https://trello.com/c/aaa"},
+		{Id: "2", Name: "Two", ShortLink: "bbb", Comments: []*types.Comment{{Text: "https://trello.com/c/missing"}}},
+		{Id: "5", Name: "Five", ShortLink: "eee", Description: "https://trello.com/c/ccc"},
+	}
+
+	want := []*linkPlan{
+		{CardId: "1", Name: "One", LinkedCards: []string{"bbb"}},
+		{CardId: "5", Name: "Five", LinkedCards: []string{"ccc"}},
+	}
+	if got := planLinks(&existing, cards); !reflect.DeepEqual(got, want) {
+		t.Errorf("planLinks = %+v, want %+v", got, want)
+	}
+}
+
+func TestExistingLinks(t *testing.T) {
+	existing := existingPages{
+		"1": {id: "page-1", database: "database", shortLink: "aaa"},
+		"2": {id: "page-2", database: "database"},
+		"3": {id: "page-3", database: "database"},
+	}
+
+	// The second card is imported again, which stores its short link
+	links, unknown := existingLinks(&existing, map[cardId]na.PageID{"2": "page-2"})
+
+	want := cardLinks{"aaa": {id: "page-1", database: "database", shortLink: "aaa"}}
+	if !reflect.DeepEqual(links, want) || unknown != 1 {
+		t.Errorf("existingLinks = %+v, %d, want %+v, 1", links, unknown, want)
+	}
+}
+
+func TestMergePageIds(t *testing.T) {
+	current := []na.PageID{"11111111-2222-3333-4444-555555555555", "page-2"}
+	found := []na.PageID{"11111111222233334444555555555555", "page-3"}
+
+	want := []na.PageID{"11111111-2222-3333-4444-555555555555", "page-2", "page-3"}
+	if got := mergePageIds(current, found); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergePageIds = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,551 @@
+package notion
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	na "github.com/jomei/notionapi"
+)
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	bulletPattern   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberedPattern = regexp.MustCompile(`^(\s*)\d+[.)]\s+(.*)$`)
+	fencePattern    = regexp.MustCompile("^\\s*(```|~~~)\\s*([\\w#+.-]*)")
+	dividerPattern  = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	quotePattern    = regexp.MustCompile(`^\s*>\s?(.*)$`)
+)
+
+// Languages supported by Notion code blocks along with the common aliases used in Markdown fences
+var codeLanguages = map[string]string{
+	"abap":          "abap",
+	"arduino":       "arduino",
+	"bash":          "bash",
+	"sh":            "shell",
+	"shell":         "shell",
+	"zsh":           "shell",
+	"basic":         "basic",
+	"c":             "c",
+	"clojure":       "clojure",
+	"coffeescript":  "coffeescript",
+	"c++":           "c++",
+	"cpp":           "c++",
+	"c#":            "c#",
+	"csharp":        "c#",
+	"cs":            "c#",
+	"css":           "css",
+	"dart":          "dart",
+	"diff":          "diff",
+	"docker":        "docker",
+	"dockerfile":    "docker",
+	"elixir":        "elixir",
+	"elm":           "elm",
+	"erlang":        "erlang",
+	"flow":          "flow",
+	"fortran":       "fortran",
+	"f#":            "f#",
+	"gherkin":       "gherkin",
+	"glsl":          "glsl",
+	"go":            "go",
+	"golang":        "go",
+	"graphql":       "graphql",
+	"groovy":        "groovy",
+	"haskell":       "haskell",
+	"html":          "html",
+	"java":          "java",
+	"javascript":    "javascript",
+	"js":            "javascript",
+	"json":          "json",
+	"julia":         "julia",
+	"kotlin":        "kotlin",
+	"latex":         "latex",
+	"tex":           "latex",
+	"less":          "less",
+	"lisp":          "lisp",
+	"livescript":    "livescript",
+	"lua":           "lua",
+	"makefile":      "makefile",
+	"make":          "makefile",
+	"markdown":      "markdown",
+	"md":            "markdown",
+	"markup":        "markup",
+	"matlab":        "matlab",
+	"mermaid":       "mermaid",
+	"nix":           "nix",
+	"objective-c":   "objective-c",
+	"ocaml":         "ocaml",
+	"pascal":        "pascal",
+	"perl":          "perl",
+	"php":           "php",
+	"powershell":    "powershell",
+	"prolog":        "prolog",
+	"protobuf":      "protobuf",
+	"python":        "python",
+	"py":            "python",
+	"r":             "r",
+	"reason":        "reason",
+	"ruby":          "ruby",
+	"rb":            "ruby",
+	"rust":          "rust",
+	"rs":            "rust",
+	"sass":          "sass",
+	"scala":         "scala",
+	"scheme":        "scheme",
+	"scss":          "scss",
+	"solidity":      "solidity",
+	"sql":           "sql",
+	"swift":         "swift",
+	"typescript":    "typescript",
+	"ts":            "typescript",
+	"vb.net":        "vb.net",
+	"verilog":       "verilog",
+	"vhdl":          "vhdl",
+	"visual basic":  "visual basic",
+	"webassembly":   "webassembly",
+	"wasm":          "webassembly",
+	"xml":           "xml",
+	"yaml":          "yaml",
+	"yml":           "yaml",
+	"java/c/c++/c#": "java/c/c++/c#",
+}
+
+const plainTextLanguage = "plain text"
+
+// Converts a Markdown document (as written in Trello descriptions and comments) into Notion blocks. Supports
+// headings, bulleted and numbered lists (with one level of nesting), fenced code blocks, quotes, dividers, and inline
+// bold, italic, strikethrough, code and links.
+func markdownBlocks(markdown string) []na.Block {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+
+	var blocks []na.Block
+	var paragraphLines []string
+
+	flushParagraph := func() {
+		if len(paragraphLines) > 0 {
+			blocks = append(blocks, richParagraph(strings.Join(paragraphLines, "\n")))
+			paragraphLines = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			continue
+		}
+
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), match[1]); i++ {
+				code = append(code, lines[i])
+			}
+
+			blocks = append(blocks, codeBlock(strings.Join(code, "\n"), match[2]))
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+			blocks = append(blocks, markdownHeading(len(match[1]), match[2]))
+			continue
+		}
+
+		if dividerPattern.MatchString(line) {
+			flushParagraph()
+			blocks = append(blocks, divider())
+			continue
+		}
+
+		if match := quotePattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+
+			quoteLines := []string{match[1]}
+			for i+1 < len(lines) && quotePattern.MatchString(lines[i+1]) {
+				i++
+				quoteLines = append(quoteLines, quotePattern.FindStringSubmatch(lines[i])[1])
+			}
+
+			blocks = append(blocks, quote(strings.Join(quoteLines, "\n")))
+			continue
+		}
+
+		if isListItem(line) {
+			flushParagraph()
+
+			var items []na.Block
+			items, i = listBlocks(lines, i)
+			blocks = append(blocks, items...)
+			continue
+		}
+
+		paragraphLines = append(paragraphLines, line)
+	}
+
+	flushParagraph()
+
+	return blocks
+}
+
+func isListItem(line string) bool {
+	return bulletPattern.MatchString(line) || numberedPattern.MatchString(line)
+}
+
+// Parses consecutive list items starting at line start. Items indented further than the first item are nested under
+// the item above them. Returns the blocks and the index of the last line consumed.
+func listBlocks(lines []string, start int) ([]na.Block, int) {
+	var blocks []na.Block
+	baseIndent := indentation(lines[start])
+
+	i := start
+	for ; i < len(lines) && isListItem(lines[i]); i++ {
+		item := listItem(lines[i])
+
+		if indentation(lines[i]) > baseIndent && len(blocks) > 0 {
+			blocks[len(blocks)-1] = withListChild(blocks[len(blocks)-1], item)
+		} else {
+			blocks = append(blocks, item)
+		}
+	}
+
+	return blocks, i - 1
+}
+
+func listItem(line string) na.Block {
+	if match := bulletPattern.FindStringSubmatch(line); match != nil {
+		return na.BulletedListItemBlock{
+			BasicBlock: basicBlock(na.BlockTypeBulletedListItem),
+			BulletedListItem: na.ListItem{
+				Text:     markdownRichText(match[2]),
+				Children: []na.Block{},
+			},
+		}
+	}
+
+	match := numberedPattern.FindStringSubmatch(line)
+	return na.NumberedListItemBlock{
+		BasicBlock: basicBlock(na.BlockTypeNumberedListItem),
+		NumberedListItem: na.ListItem{
+			Text:     markdownRichText(match[2]),
+			Children: []na.Block{},
+		},
+	}
+}
+
+// Nests child under parent. Blocks are stored by value so the updated parent is returned.
+func withListChild(parent na.Block, child na.Block) na.Block {
+	switch p := parent.(type) {
+	case na.BulletedListItemBlock:
+		p.BulletedListItem.Children = append(p.BulletedListItem.Children, child)
+		return p
+	case na.NumberedListItemBlock:
+		p.NumberedListItem.Children = append(p.NumberedListItem.Children, child)
+		return p
+	}
+
+	return parent
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+}
+
+func markdownHeading(level int, text string) na.Block {
+	rt := markdownRichText(text)
+
+	switch level {
+	case 1:
+		return na.Heading1Block{
+			BasicBlock: basicBlock(na.BlockTypeHeading1),
+			Heading1:   na.Heading{Text: rt},
+		}
+	case 2:
+		return na.Heading2Block{
+			BasicBlock: basicBlock(na.BlockTypeHeading2),
+			Heading2:   na.Heading{Text: rt},
+		}
+	default:
+		return na.Heading3Block{
+			BasicBlock: basicBlock(na.BlockTypeHeading3),
+			Heading3:   na.Heading{Text: rt},
+		}
+	}
+}
+
+func richParagraph(text string) na.ParagraphBlock {
+	return na.ParagraphBlock{
+		BasicBlock: basicBlock(na.BlockTypeParagraph),
+		Paragraph: na.Paragraph{
+			Text:     markdownRichText(text),
+			Children: []na.Block{},
+		},
+	}
+}
+
+func codeBlock(code, language string) na.CodeBlock {
+	lang, ok := codeLanguages[strings.ToLower(language)]
+	if !ok {
+		lang = plainTextLanguage
+	}
+
+	return na.CodeBlock{
+		BasicBlock: basicBlock(na.BlockTypeCode),
+		Code: na.Code{
+			Text:     richText(code, noLink),
+			Language: lang,
+		},
+	}
+}
+
+func quote(text string) na.QuoteBlock {
+	return na.QuoteBlock{
+		BasicBlock: basicBlock(na.BlockQuote),
+		Quote: na.Quote{
+			Text:     markdownRichText(text),
+			Children: []na.Block{},
+		},
+	}
+}
+
+func divider() na.DividerBlock {
+	return na.DividerBlock{
+		BasicBlock: basicBlock(na.BlockTypeDivider),
+	}
+}
+
+// Inline formatting state while parsing a Markdown span
+type inlineStyle struct {
+	bold          bool
+	italic        bool
+	strikethrough bool
+	code          bool
+	link          string
+}
+
+func (s inlineStyle) richText(content string) []na.RichText {
+	link := noLink
+	if s.link != "" {
+		link = s.link
+	}
+
+	texts := richText(content, link)
+	if !s.bold && !s.italic && !s.strikethrough && !s.code {
+		return texts
+	}
+
+	for i := range texts {
+		texts[i].Annotations = &na.Annotations{
+			Bold:          s.bold,
+			Italic:        s.italic,
+			Strikethrough: s.strikethrough,
+			Code:          s.code,
+			Color:         na.ColorDefault,
+		}
+	}
+
+	return texts
+}
+
+// Converts inline Markdown into annotated rich text
+func markdownRichText(text string) []na.RichText {
+	texts := parseInline(text, inlineStyle{})
+	if len(texts) == 0 {
+		return richText("", noLink)
+	}
+
+	return texts
+}
+
+func parseInline(text string, style inlineStyle) []na.RichText {
+	var texts []na.RichText
+	var buffer strings.Builder
+
+	flush := func() {
+		if buffer.Len() > 0 {
+			texts = append(texts, style.richText(buffer.String())...)
+			buffer.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_~[]()#+-.!>", rune(rest[1])):
+			buffer.WriteByte(rest[1])
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			if end := strings.Index(rest[1:], "`"); end > 0 {
+				flush()
+				codeStyle := style
+				codeStyle.code = true
+				texts = append(texts, codeStyle.richText(rest[1:end+1])...)
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				flush()
+				boldStyle := style
+				boldStyle.bold = true
+				texts = append(texts, parseInline(rest[2:end+2], boldStyle)...)
+				i += end + 4
+				continue
+			}
+
+		case strings.HasPrefix(rest, "~~"):
+			if end := strings.Index(rest[2:], "~~"); end > 0 {
+				flush()
+				strikeStyle := style
+				strikeStyle.strikethrough = true
+				texts = append(texts, parseInline(rest[2:end+2], strikeStyle)...)
+				i += end + 4
+				continue
+			}
+
+		case (rest[0] == '*' || rest[0] == '_') && isWordStart(text, i):
+			if end := closingDelimiter(rest[1:], rest[0]); end > 0 {
+				flush()
+				italicStyle := style
+				italicStyle.italic = true
+				texts = append(texts, parseInline(rest[1:end+1], italicStyle)...)
+				i += end + 2
+				continue
+			}
+
+		case rest[0] == '[':
+			if label, link, length, ok := parseLink(rest); ok {
+				flush()
+				linkStyle := style
+				linkStyle.link = link
+				texts = append(texts, parseInline(label, linkStyle)...)
+				i += length
+				continue
+			}
+
+		case style.link == "" && (strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://")) && isWordStart(text, i):
+			link := bareLink(rest)
+			if isValidLink(link) {
+				flush()
+				linkStyle := style
+				linkStyle.link = link
+				texts = append(texts, linkStyle.richText(link)...)
+				i += len(link)
+				continue
+			}
+		}
+
+		buffer.WriteByte(text[i])
+		i++
+	}
+
+	flush()
+
+	return texts
+}
+
+// An emphasis delimiter only opens at the start of a word so that snake_case and 2*3*4 are left as they are
+func isWordStart(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	previous, _ := utf8.DecodeLastRuneInString(text[:i])
+	return !isWordRune(previous)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Finds the closing single emphasis delimiter, ignoring doubled delimiters and delimiters preceded by whitespace
+func closingDelimiter(text string, delimiter byte) int {
+	for i := 1; i < len(text); i++ {
+		if text[i] != delimiter {
+			continue
+		}
+
+		if i+1 < len(text) && text[i+1] == delimiter {
+			i++
+			continue
+		}
+
+		if previous, _ := utf8.DecodeLastRuneInString(text[:i]); unicode.IsSpace(previous) {
+			continue
+		}
+
+		if next, _ := utf8.DecodeRuneInString(text[i+1:]); i+1 < len(text) && isWordRune(next) {
+			continue
+		}
+
+		return i
+	}
+
+	return -1
+}
+
+// Parses [label](link) at the start of text, returning the label, link and number of bytes consumed
+func parseLink(text string) (label, link string, length int, ok bool) {
+	labelEnd := strings.Index(text, "](")
+	if labelEnd < 0 {
+		return "", "", 0, false
+	}
+
+	linkEnd := strings.Index(text[labelEnd+2:], ")")
+	if linkEnd < 0 {
+		return "", "", 0, false
+	}
+
+	label = text[1:labelEnd]
+	link = strings.TrimSpace(text[labelEnd+2 : labelEnd+2+linkEnd])
+	// Drop any link title, i.e. [label](link "title")
+	if space := strings.IndexAny(link, " \t"); space >= 0 {
+		link = link[:space]
+	}
+
+	if !isValidLink(link) {
+		return "", "", 0, false
+	}
+
+	if label == "" {
+		label = link
+	}
+
+	return label, link, labelEnd + 2 + linkEnd + 1, true
+}
+
+func bareLink(text string) string {
+	end := strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '<' || r == '>'
+	})
+	if end < 0 {
+		end = len(text)
+	}
+
+	return strings.TrimRight(text[:end], ".,;:!?)]'\"")
+}
+
+// Notion rejects rich text links that are not absolute URLs
+func isValidLink(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	default:
+		return false
+	}
+}
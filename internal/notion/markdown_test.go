@@ -0,0 +1,170 @@
+package notion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	na "github.com/jomei/notionapi"
+)
+
+// Describes rich text with its annotations written back as Markdown, so that expectations read like the input. Italic
+// text is always written with asterisks, so that underscores left as they are stand out from emphasis.
+func describeRichText(texts []na.RichText) string {
+	var description strings.Builder
+	for _, text := range texts {
+		content := text.Text.Content
+		if a := text.Annotations; a != nil {
+			if a.Code {
+				content = "`" + content + "`"
+			}
+			if a.Italic {
+				content = "*" + content + "*"
+			}
+			if a.Bold {
+				content = "**" + content + "**"
+			}
+			if a.Strikethrough {
+				content = "~~" + content + "~~"
+			}
+		}
+		if text.Text.Link != nil {
+			content = "[" + content + "](" + text.Text.Link.Url + ")"
+		}
+
+		description.WriteString(content)
+	}
+
+	return description.String()
+}
+
+// Describes every block as its type and text, with nested blocks indented under their parent
+func describeBlocks(blocks []na.Block, indent string) []string {
+	var descriptions []string
+	for _, block := range blocks {
+		var text []na.RichText
+		var children []na.Block
+		extra := ""
+
+		switch b := block.(type) {
+		case na.Heading1Block:
+			text = b.Heading1.Text
+		case na.Heading2Block:
+			text = b.Heading2.Text
+		case na.Heading3Block:
+			text = b.Heading3.Text
+		case na.ParagraphBlock:
+			text, children = b.Paragraph.Text, b.Paragraph.Children
+		case na.BulletedListItemBlock:
+			text, children = b.BulletedListItem.Text, b.BulletedListItem.Children
+		case na.NumberedListItemBlock:
+			text, children = b.NumberedListItem.Text, b.NumberedListItem.Children
+		case na.QuoteBlock:
+			text, children = b.Quote.Text, b.Quote.Children
+		case na.CalloutBlock:
+			text, children = b.Callout.Text, b.Callout.Children
+		case na.CodeBlock:
+			text, extra = b.Code.Text, " ("+b.Code.Language+")"
+		}
+
+		descriptions = append(descriptions, indent+string(block.GetType())+extra+": "+describeRichText(text))
+		descriptions = append(descriptions, describeBlocks(children, indent+"  ")...)
+	}
+
+	return descriptions
+}
+
+func TestMarkdownBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []string
+	}{
+		{
+			name:     "headings",
+			markdown: "# One\n## Two\n### Three\n#### Four ##",
+			want:     []string{"heading_1: One", "heading_2: Two", "heading_3: Three", "heading_3: Four"},
+		},
+		{
+			name:     "paragraphs are split on blank lines",
+			markdown: "first line\nsecond line\n\nnext paragraph",
+			want:     []string{"paragraph: first line\nsecond line", "paragraph: next paragraph"},
+		},
+		{
+			name:     "bulleted list with nesting",
+			markdown: "- one\n* two\n  + nested\n- three",
+			want: []string{
+				"bulleted_list_item: one",
+				"bulleted_list_item: two",
+				"  bulleted_list_item: nested",
+				"bulleted_list_item: three",
+			},
+		},
+		{
+			name:     "numbered list",
+			markdown: "1. one\n2) two",
+			want:     []string{"numbered_list_item: one", "numbered_list_item: two"},
+		},
+		{
+			name:     "fenced code with a language alias",
+			markdown: "```js\nconst a = 1\n**not bold**\n```",
+			want:     []string{"code (javascript): const a = 1\n**not bold**"},
+		},
+		{
+			name:     "fenced code with an unknown language",
+			markdown: "~~~brainfuck\n+++\n~~~",
+			want:     []string{"code (plain text): +++"},
+		},
+		{
+			name:     "quote over several lines",
+			markdown: "> quoted\n> **still** quoted\nafter",
+			want:     []string{"quote: quoted\n**still** quoted", "paragraph: after"},
+		},
+		{
+			name:     "dividers",
+			markdown: "above\n\n---\n* * *\nbelow",
+			want:     []string{"paragraph: above", "divider: ", "divider: ", "paragraph: below"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := describeBlocks(markdownBlocks(test.markdown), "")
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("markdownBlocks(%q)\n got: %q\nwant: %q", test.markdown, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownRichText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "plain text", "plain text"},
+		{"bold", "a **bold** b __bold__", "a **bold** b **bold**"},
+		{"italic", "an *italic* and _italic_ word", "an *italic* and *italic* word"},
+		{"strikethrough", "~~gone~~", "~~gone~~"},
+		{"code is not parsed further", "`a *b* c`", "`a *b* c`"},
+		{"nested emphasis", "**bold _both_**", "**bold *****both***"},
+		{"link", "see [the docs](https://example.com/docs \"title\")", "see [the docs](https://example.com/docs)"},
+		{"bare link", "at https://example.com/a_b_c.", "at [https://example.com/a_b_c](https://example.com/a_b_c)."},
+		{"relative links are left as text", "[docs](/docs)", "[docs](/docs)"},
+		{"escaped delimiters", `\*not italic\*`, "*not italic*"},
+		{"snake_case", "snake_case_name", "snake_case_name"},
+		{"multiplication", "2*3*4", "2*3*4"},
+		{"snake_case in other scripts", "ключ_значение_.", "ключ_значение_."},
+		{"emphasis after other scripts", "слово *курсив*", "слово *курсив*"},
+		{"closing delimiter before other scripts", "_a_א", "_a_א"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := describeRichText(markdownRichText(test.text)); got != test.want {
+				t.Errorf("markdownRichText(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package notion
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/env"
+	"github.com/woojiahao/baleen/internal/types"
+	"golang.org/x/net/context"
+)
+
+const (
+	// People property holding the card members that are mapped to Notion users
+	assigneesProperty = "Assignees"
+	// Multi-select property holding the card members that are not mapped to a Notion user
+	unmappedAssigneesProperty = "Trello Members"
+)
+
+// Splits the members into the IDs of the Notion users they are mapped to and the names of the members that are not
+// mapped
+func splitMembers(config *config.Config, members []*types.Member) (userIds, unmapped []string) {
+	for _, member := range members {
+		if id, ok := config.NotionUserId(member.Username, member.Email); ok {
+			userIds = append(userIds, id)
+		} else {
+			unmapped = append(unmapped, memberName(member))
+		}
+	}
+
+	return
+}
+
+// Name of the member's option in the multi-select. Full names are not unique, so the username is added to tell apart
+// members with the same full name. Notion does not allow commas in option names.
+func memberName(member *types.Member) string {
+	name := member.FullName
+	switch {
+	case name != "" && member.Username != "":
+		name = fmt.Sprintf("%s (@%s)", name, member.Username)
+	case name == "":
+		name = member.Username
+	}
+	if name == "" {
+		name = member.Id
+	}
+
+	return strings.ReplaceAll(name, ",", "")
+}
+
+// Adds the card's members to the unique members
+func extractMembers(members []*types.Member, card *types.Card) []*types.Member {
+	for _, member := range card.Members {
+		if !containsMember(member.Id, members) {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+func containsMember(id string, members []*types.Member) bool {
+	for _, member := range members {
+		if member.Id == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prints the users of the Notion workspace, to help fill in the member mapping of the config
+func ListUsers(envPath string) {
+	env := env.New(envPath)
+	notion := notionapi.NewClient(
+		notionapi.Token(env.NotionKey),
+		notionapi.WithHTTPClient(newRateLimitedClient(DefaultRequestsPerSecond)),
+	)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tEMAIL\tTYPE")
+
+	var cursor notionapi.Cursor
+	for {
+		resp, err := notion.User.List(context.Background(), &notionapi.Pagination{StartCursor: cursor, PageSize: 100})
+		if err != nil {
+			log.Fatalf("Failed to list Notion users: %v\n", err)
+		}
+
+		for _, user := range resp.Results {
+			email := ""
+			if user.Person != nil {
+				email = user.Person.Email
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", user.ID, user.Name, email, user.Type)
+		}
+
+		if !resp.HasMore {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	w.Flush()
+}
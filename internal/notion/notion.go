@@ -2,9 +2,10 @@ package notion
 
 import (
 	"encoding/json"
-	"io"
+	"fmt"
 	"log"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jomei/notionapi"
@@ -14,6 +15,9 @@ import (
 	"golang.org/x/net/context"
 )
 
+// Folder that the cards that failed to import are saved to
+const errorSaveDir = "data/errors"
+
 // Types for database metadata
 type (
 	databaseName    string
@@ -40,174 +44,308 @@ func (a *attachments) first() (attachmentName, attachmentLink) {
 	return "null", "null"
 }
 
-func (a *attachments) toMap() map[string]string {
-	m := make(map[string]string)
-	for name, link := range *a {
-		m[string(name)] = string(link)
-	}
-
-	return m
+// Options for importing cards into Notion
+type ImportOptions struct {
+	// Path of the checkpoint journal recording every imported card. No journal is kept when empty.
+	JournalPath string
+	// Skip the cards that the journal has already recorded as imported
+	Resume bool
+	// Maximum number of requests sent to Notion per second. Defaults to DefaultRequestsPerSecond when not positive.
+	RequestsPerSecond float64
+	// Number of cards imported at the same time
+	Concurrency int
+	// Print the changes that the import would make instead of making them
+	DryRun bool
+	// Page to create any missing databases under. Overrides the parentPage set in the config.
+	ParentPage string
+	// File that the dry run writes the full JSON payload of every request to. Only the summary is printed when empty.
+	PlanPath string
 }
 
-// Import a set of cards into Notion. The cards should either be loaded from a file with LoadCardsFromExport or directly
-// from trello.ExtractTrelloBoard.
-func ImportToNotion(cards []*types.Card, envPath, configPath string) {
+// Import a set of cards into Notion. The cards should either be loaded from a file with LoadSave or directly from
+// trello.ExportTrelloBoard, along with the rest of the save that they are from. The cards are read once for every pass
+// over them, so a streamed save is never held in memory. Returns the number of cards that failed to import.
+func ImportToNotion(save *types.Save, cards types.CardStream, envPath, configPath string, options ImportOptions) int {
 	log.Printf("Importing cards into Notion\n")
 
 	env := env.New(envPath)
-	notion := notionapi.NewClient(notionapi.Token(env.NotionKey))
+	httpClient := newRateLimitedClient(options.RequestsPerSecond)
+	notion := notionapi.NewClient(notionapi.Token(env.NotionKey), notionapi.WithHTTPClient(httpClient))
 
 	config := config.New(configPath)
-	nameIds := getDatabaseNameIds(notion, config.DatabaseNames())
-	labels := extractLabels(config, cards)
+	checkListMappings(config, cards)
+	nameIds, missing := getDatabaseNameIds(notion, config.DatabaseNames())
+	schema := extractSchema(config, save, cards)
+
+	parentPage := options.ParentPage
+	if parentPage == "" {
+		parentPage = config.ParentPage
+	}
+
+	if options.DryRun {
+		planImport(config, notion, nameIds, missing, parentPage, schema, cards, options)
+		return 0
+	}
+
+	createMissingDatabases(config, notion, nameIds, missing, parentPage, schema)
+
+	addDatabaseProperties(config, notion, nameIds, schema)
+	existing := getExistingPages(notion, nameIds)
 
-	addDatabaseProperties(config, notion, nameIds, labels)
-	importCards(config, notion, nameIds, cards)
+	var journal *journal
+	if options.JournalPath != "" {
+		journal = openJournal(options.JournalPath, options.Resume)
+		defer journal.close()
+	}
+
+	pipeline := newAttachmentPipeline(config, env)
+	poster := newCommentPoster(config, httpClient, env.NotionKey)
+
+	pages, failed := importCards(config, notion, nameIds, existing, journal, pipeline, poster, cards, options.Concurrency)
+	patcher := &blockPatcher{httpClient, env.NotionKey}
+	linkCards(config, notion, patcher, nameIds, existing, pages, cards, options.Concurrency)
+
+	return failed
 }
 
-func LoadSave(exportPath string) []*types.Card {
+// Loads the cards from a save, upgrading saves written by older versions of baleen. Returns everything in the save but
+// its cards along with the cards, which are read from a streamed save as they are needed.
+func LoadSave(exportPath string) (*types.Save, types.CardStream) {
 	log.Printf("Loading cards from save %s\n", exportPath)
 
-	jsonFile, err := os.Open(exportPath)
+	save, cards, err := types.OpenCards(exportPath)
 	if err != nil {
-		log.Fatalf("Error occurred: %v\n", err)
+		log.Fatalf("Failed to load save %s: %v\n", exportPath, err)
 	}
 
-	defer jsonFile.Close()
+	if save.Board != nil && save.ExportedAt != nil {
+		log.Printf(
+			"Loaded save of board %s exported at %s\n",
+			save.Board.Name,
+			save.ExportedAt.Format(time.RFC3339),
+		)
+	}
 
-	data, _ := io.ReadAll(jsonFile)
-	var cards []*types.Card
-	json.Unmarshal(data, &cards)
+	return save, cards
+}
 
-	return cards
+// Calls each with every card, stopping the import if the cards cannot be read
+func eachCard(cards types.CardStream, each func(*types.Card)) {
+	err := cards.Each(func(card *types.Card) error {
+		each(card)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read cards: %v\n", err)
+	}
 }
 
-// Add a card to its respective database
-func importCards(config *config.Config, notion *notionapi.Client, nameIds *databaseNameIds, cards []*types.Card) {
+// Add a card to its respective database. Returns the pages that the cards are now in and the number of cards that
+// failed to import.
+func importCards(
+	config *config.Config,
+	notion *notionapi.Client,
+	nameIds *databaseNameIds,
+	existing *existingPages,
+	journal *journal,
+	pipeline *attachmentPipeline,
+	poster *commentPoster,
+	cards types.CardStream,
+	concurrency int,
+) (map[cardId]notionapi.PageID, int) {
 	log.Printf("Adding cards to database\n")
 
-	chunkSize := 3
-	chunks := types.ChunkEvery(cards, chunkSize)
-	c := make(chan *types.Card, chunkSize)
-	var errCards []*types.Card
+	pages := make(map[cardId]notionapi.PageID)
+	for id, page := range *existing {
+		pages[id] = page.id
+	}
 
-	for i, chunk := range chunks {
-		for _, card := range chunk {
-			go importCard(config, notion, nameIds, card, c)
+	total, skipped := 0, 0
+	eachCard(cards, func(card *types.Card) {
+		if page, ok := journal.page(card.Id); ok {
+			pages[cardId(card.Id)] = page
+			skipped++
+		} else {
+			total++
 		}
+	})
 
-		// Account for chunks with less than 3 cards by creating nil card that importCard will ignore
-		for j := 0; j < chunkSize-len(chunks)-1; j++ {
-			go importCard(config, notion, nameIds, nil, c)
-		}
+	if skipped > 0 {
+		log.Printf("Skipping %d cards already imported\n", skipped)
+	}
 
-		f, s, t := <-c, <-c, <-c
-		if f != nil {
-			errCards = append(errCards, f)
-		}
+	// The journal only records cards once they are imported, so it holds the same cards here as in the pass above
+	remaining := types.FilterCards(cards, func(card *types.Card) bool {
+		return !journal.isImported(card.Id)
+	})
 
-		if s != nil {
-			errCards = append(errCards, s)
+	var errCards []*types.Card
+	imported := 0
+	var mu sync.Mutex
+
+	err := types.ProcessCardStream(remaining, concurrency, func(card *types.Card) error {
+		page, err := importCard(config, notion, nameIds, existing, journal, pipeline, poster, card)
+		if err == nil {
+			mu.Lock()
+			pages[cardId(card.Id)] = page
+			mu.Unlock()
 		}
 
-		if t != nil {
-			errCards = append(errCards, t)
+		return err
+	}, func(result types.CardResult) {
+		if result.Err != nil {
+			log.Printf("Unable to add card %s. Saving for inspection later.\n", result.Card.Name)
+			errCards = append(errCards, result.Card)
+		} else {
+			log.Printf("Added card %s\n", result.Card.Name)
+			imported++
 		}
 
-		if (i+1)%15 == 0 {
-			log.Printf("Imported %d/%d\n", i+1, len(chunks))
+		if (result.Index+1)%50 == 0 {
+			log.Printf("Processed %d/%d\n", result.Index+1, total)
 		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to read cards: %v\n", err)
 	}
 
-	log.Printf("Imported all cards!\n")
+	log.Printf("Imported %d/%d cards!\n", imported, total)
 
 	if errCards != nil {
-		errPath := types.SaveCards(errCards, "errors")
+		errPath := types.SaveCards(types.NewSave(nil, nil, nil, nil, errCards), types.SaveLocation{Dir: errorSaveDir})
 		log.Printf("Saved error cards to %s\n", errPath)
 	}
+
+	return pages, len(errCards)
 }
 
-// Emits nil to the channel if the card didn't occur any errors. A returned card will indicate that the card has failed
-// to be added.
+// Adds the card to its database. Cards that were imported before are updated in place rather than added again. Added
+// cards are recorded in the journal. Comments are only posted to pages that are created, as posting them again on every
+// update would duplicate them.
 func importCard(
 	config *config.Config,
 	notion *notionapi.Client,
 	nameIds *databaseNameIds,
+	existing *existingPages,
+	journal *journal,
+	pipeline *attachmentPipeline,
+	poster *commentPoster,
 	card *types.Card,
-	c chan *types.Card,
-) {
-	if card == nil {
-		c <- nil
-		return
+) (notionapi.PageID, error) {
+	built := buildPage(config, nameIds, pipeline, card)
+
+	var page *existingPage
+	// The comments of a page left behind by a failed import were never posted
+	partial := false
+	if p, ok := (*existing)[cardId(card.Id)]; ok {
+		page = &p
+	} else if p, ok := journal.partialPage(card.Id); ok {
+		page, partial = &p, true
 	}
+	created := page == nil || partial || page.database != built.database
 
-	fileAttachments, urlAttachments := organizeAttachments(card)
+	// Retryable errors are already retried by the rate limited transport so any error here is final
+	page, err := upsertCard(notion, page, built)
+	if err != nil {
+		log.Printf("Error occurred when adding card (%s) to database: %v\n", card.Name, err)
 
-	_, pl := urlAttachments.first()
+		// The page that now holds the card is kept so that importing the card again finishes it
+		if page != nil && page.database == built.database {
+			journal.recordPartial(card.Id, *page)
+		}
 
-	properties := createProperties(config, card, primaryLink(pl))
-	children := createChildren(fileAttachments, urlAttachments, card.Description, card.Comments)
+		j, _ := json.MarshalIndent(built.properties, "", "  ")
+		log.Printf("Properties were: %v\n", string(j))
 
-	tries, maxTries := 1, 3
+		return "", err
+	}
 
-	request := &notionapi.PageCreateRequest{
-		Parent: notionapi.Parent{
-			DatabaseID: notionapi.DatabaseID((*nameIds)[databaseName(config.Database[card.ParentListName])]),
-		},
-		Properties: properties,
-		Children:   children,
+	if created {
+		if err := poster.post(page.id, card.Comments); err != nil {
+			log.Printf("Failed to post the comments of card %s as Notion comments: %v\n", card.Name, err)
+		}
 	}
 
-	_, err := notion.Page.Create(context.Background(), request)
+	journal.record(card.Id, page.id)
 
-	// Try three more times in case it's just a timeout issue
-	for err != nil && tries < maxTries {
-		tries++
-		wait := tries * 2
+	return page.id, nil
+}
 
-		log.Printf("Error occurred when adding card (%s) to database: %v\n", card.Name, err)
+// Builds the page for a card in the database it belongs in
+func buildPage(
+	config *config.Config,
+	nameIds *databaseNameIds,
+	pipeline *attachmentPipeline,
+	card *types.Card,
+) *cardPage {
+	_, urlAttachments := organizeAttachments(card)
 
-		j, _ := json.MarshalIndent(request, "", "  ")
-		log.Printf("Request was: %v\n", string(j))
+	_, pl := urlAttachments.first()
 
-		log.Printf("Trying again after %ds wait... (Try %d)\n", wait, tries)
-		time.Sleep(time.Duration(wait) * time.Second)
+	files := pipeline.files(card)
+	children := createChildren(
+		fileBlocks(files),
+		urlBlocks(config, card),
+		commentBlocks(config, card.Comments),
+		card.Description,
+		card.Checklists,
+	)
+
+	return &cardPage{
+		database:   (*nameIds)[databaseName(config.DatabaseName(card.ParentListName, card.Archived))],
+		properties: createProperties(config, card, primaryLink(pl)),
+		children:   children,
+		cover:      pageCover(card, files),
+		icon:       pageIcon(config, card),
+	}
+}
 
-		_, err = notion.Page.Create(context.Background(), request)
+// Updates the page previously imported from the card or creates a new one. A page that is in a different database
+// from the one the card now maps to is archived and recreated in the new database. Returns the page that now holds the
+// card even when an error follows, which the journal records so that a retry after a partial failure does not create the
+// page again.
+func upsertCard(notion *notionapi.Client, page *existingPage, built *cardPage) (*existingPage, error) {
+	if page != nil && page.database == built.database {
+		return page, updatePage(notion, page.id, built)
+	}
+
+	if page != nil {
+		if err := archivePage(notion, page.id); err != nil {
+			return page, err
+		}
 	}
 
-	if tries >= maxTries {
-		log.Printf("Unable to add card %s. Saving for inspection later.\n", card.Name)
-		c <- card
-	} else {
-		log.Printf("Added card %s\n", card.Name)
-		c <- nil
+	id, err := createPage(notion, built)
+	if id == "" {
+		return nil, err
 	}
+
+	return &existingPage{id: id, database: built.database}, err
 }
 
 // Add the necessary properties for importing Trello information into a Notion card
-// Properties include a Description, Primary Link (first link in the attachments), Labels, and Last Updated
 func addDatabaseProperties(
 	config *config.Config,
 	notion *notionapi.Client,
 	nameIds *databaseNameIds,
-	labels []*types.Label,
+	schema *boardSchema,
 ) {
 	log.Printf("Adding properties to database")
 
-	labelOptions := organizeLabels(config, labels)
-
 	for name, id := range *nameIds {
-		properties := make(notionapi.PropertyConfigs)
-		properties["Description"] = richTextConfig()
-		properties["Primary Link"] = urlConfig()
-		if len(labelOptions) > 0 {
-			properties["Labels"] = multiSelectConfig(labelOptions)
+		properties := databaseProperties(config, schema)
+		if config.RelatedCards {
+			properties[relatedCardsProperty] = relationConfig(notionapi.DatabaseID(id))
+		}
+
+		database, err := notion.Database.Get(context.Background(), notionapi.DatabaseID(id))
+		if err != nil {
+			log.Fatalf("Failed to get database %s: %v\n", name, err)
 		}
-		properties["Last Updated"] = dateConfig()
+		keepOptionColors(database, properties)
 
 		request := &notionapi.DatabaseUpdateRequest{Properties: properties}
-		_, err := notion.Database.Update(context.Background(), notionapi.DatabaseID(id), request)
+		_, err = notion.Database.Update(context.Background(), notionapi.DatabaseID(id), request)
 
 		if err != nil {
 			log.Fatalf("Failed to add properties to %s: %v\n", name, err)
@@ -215,12 +353,86 @@ func addDatabaseProperties(
 	}
 }
 
+// Properties include the Trello ID (used to find previously imported cards), the Trello Short Link (used to link cards
+// to each other), a Description, Primary Link (first link in the attachments), Labels, Last Updated, Due (start and due
+// date range), Done, and Archived. A single database also gets a select property holding the list each card was in.
+// Card members become Assignees when they are mapped to a Notion user and are kept in a multi-select otherwise. Every
+// custom field used on the board gets a property of the matching type.
+func databaseProperties(config *config.Config, schema *boardSchema) notionapi.PropertyConfigs {
+	labelOptions := organizeLabels(config, schema.labels)
+
+	properties := make(notionapi.PropertyConfigs)
+	properties[cardIdProperty] = richTextConfig()
+	properties[shortLinkProperty] = richTextConfig()
+	properties["Description"] = richTextConfig()
+	properties["Primary Link"] = urlConfig()
+	if len(labelOptions) > 0 {
+		properties["Labels"] = multiSelectConfig(labelOptions)
+	}
+	properties["Last Updated"] = dateConfig()
+	properties["Due"] = dateConfig()
+	properties["Done"] = checkboxConfig()
+	properties["Archived"] = checkboxConfig()
+	if config.Single != nil {
+		properties[config.Single.ListPropertyName()] = selectConfig(colorOptions(schema.lists))
+	}
+	if len(schema.members) > 0 {
+		properties[assigneesProperty] = peopleConfig()
+	}
+	if _, unmapped := splitMembers(config, schema.members); len(unmapped) > 0 {
+		properties[unmappedAssigneesProperty] = multiSelectConfig(colorOptions(unmapped))
+	}
+	addCustomFieldConfigs(config, properties, schema.customFields)
+
+	return properties
+}
+
+// Leaves out the colors of the select and multi-select options that the database already has. Notion rejects changes to
+// the color of an existing option, which options created by older imports or recolored in Notion would otherwise hit.
+func keepOptionColors(database *notionapi.Database, properties notionapi.PropertyConfigs) {
+	for name, property := range properties {
+		switch config := property.(type) {
+		case notionapi.SelectPropertyConfig:
+			if current, ok := database.Properties[name].(*notionapi.SelectPropertyConfig); ok {
+				config.Select.Options = withoutExistingColors(current.Select.Options, config.Select.Options)
+				properties[name] = config
+			}
+		case notionapi.MultiSelectPropertyConfig:
+			if current, ok := database.Properties[name].(*notionapi.MultiSelectPropertyConfig); ok {
+				config.MultiSelect.Options = withoutExistingColors(current.MultiSelect.Options, config.MultiSelect.Options)
+				properties[name] = config
+			}
+		}
+	}
+}
+
+func withoutExistingColors(current, options []notionapi.Option) []notionapi.Option {
+	existing := make(map[string]bool)
+	for _, option := range current {
+		existing[option.Name] = true
+	}
+
+	var kept []notionapi.Option
+	for _, option := range options {
+		if existing[option.Name] {
+			option.Color = ""
+		}
+		kept = append(kept, option)
+	}
+
+	return kept
+}
+
 func createProperties(config *config.Config, card *types.Card, pl primaryLink) notionapi.Properties {
 	labelOptions := organizeLabels(config, card.Labels)
 
 	properties := notionapi.Properties{}
 
 	properties["Name"] = titleProperty(card.Name)
+	properties[cardIdProperty] = richTextProperty(card.Id, noLink)
+	if card.ShortLink != "" {
+		properties[shortLinkProperty] = richTextProperty(card.ShortLink, noLink)
+	}
 
 	description := card.Description
 	descriptionLimit := 100
@@ -237,33 +449,66 @@ func createProperties(config *config.Config, card *types.Card, pl primaryLink) n
 		properties["Last Updated"] = dateProperty(card.LastUpdate)
 	}
 
+	if card.Due != nil {
+		properties["Due"] = dateRangeProperty(card.Start, card.Due)
+	} else if card.Start != nil {
+		properties["Due"] = dateProperty(card.Start)
+	}
+
+	properties["Done"] = checkboxProperty(card.DueComplete)
+	properties["Archived"] = checkboxProperty(card.Archived)
+
+	if config.Single != nil {
+		properties[config.Single.ListPropertyName()] = selectProperty(config.ListOption(card.ParentListName))
+	}
+
 	if len(labelOptions) > 0 {
 		properties["Labels"] = multiSelectProperty(labelOptions)
 	}
 
+	if len(card.Members) > 0 {
+		userIds, unmapped := splitMembers(config, card.Members)
+		properties[assigneesProperty] = peopleProperty(userIds)
+		if len(unmapped) > 0 {
+			properties[unmappedAssigneesProperty] = multiSelectProperty(nameOptions(unmapped))
+		}
+	}
+
+	addCustomFieldProperties(config, properties, card)
+
 	return properties
 }
 
-func createChildren(fileAttachments, urlAttachments *attachments, description string, comments []string) []notionapi.Block {
+func createChildren(
+	fileBlocks []notionapi.Block,
+	urlBlocks []notionapi.Block,
+	commentBlocks []notionapi.Block,
+	description string,
+	checklists []*types.Checklist,
+) []notionapi.Block {
 	var children []notionapi.Block
 
 	children = append(children, heading1("File Attachments"))
-	for _, file := range linkBlocks(fileAttachments.toMap()) {
-		children = append(children, file)
-	}
+	children = append(children, fileBlocks...)
 
 	children = append(children, heading1("URL Attachments"))
-	for _, url := range linkBlocks(urlAttachments.toMap()) {
-		children = append(children, url)
-	}
+	children = append(children, urlBlocks...)
 
 	children = append(children, heading1("Comments"))
-	for _, comment := range comments {
-		children = append(children, paragraph(comment))
+	children = append(children, commentBlocks...)
+
+	if len(checklists) > 0 {
+		children = append(children, heading1("Checklists"))
+		for _, checklist := range checklists {
+			children = append(children, heading2(checklist.Name))
+			for _, item := range checklist.Items {
+				children = append(children, toDo(item.Name, item.Checked))
+			}
+		}
 	}
 
 	children = append(children, heading1("Description"))
-	children = append(children, paragraph(description))
+	children = append(children, markdownBlocks(description)...)
 
 	return children
 }
@@ -305,51 +550,194 @@ func organizeAttachments(card *types.Card) (fileAttachments, urlAttachments *att
 	return
 }
 
-// Extracts the unique labels from a list of cards
-func extractLabels(config *config.Config, cards []*types.Card) []*types.Label {
-	labelsMap := make(map[string]string)
+// Board wide values that the database properties need options for
+type boardSchema struct {
+	labels []*types.Label
+	// Names of the lists after mapping, in the order they were first seen
+	lists        []string
+	members      []*types.Member
+	customFields []*customFieldSchema
+}
+
+// Extracts the schema in a single pass over the cards. The lists of the board come first in the order they are on the
+// board, followed by any lists that only the cards name, such as in saves written before the lists were saved.
+func extractSchema(config *config.Config, save *types.Save, cards types.CardStream) *boardSchema {
+	schema := &boardSchema{}
+	for _, list := range save.Lists {
+		schema.lists = extractList(config, schema.lists, list.Name)
+	}
+	schema.labels = extractLabels(config, schema.labels, save.Labels)
+	schema.customFields = extractCustomFieldDefinitions(schema.customFields, save.CustomFields)
+
+	eachCard(cards, func(card *types.Card) {
+		schema.lists = extractList(config, schema.lists, card.ParentListName)
+		schema.labels = extractLabels(config, schema.labels, card.Labels)
+		schema.members = extractMembers(schema.members, card)
+		schema.customFields = extractCustomFields(schema.customFields, card)
+	})
 
-	for _, card := range cards {
-		for _, label := range card.Labels {
-			if alt, ok := config.Color[label.Color]; ok {
-				labelsMap[label.Name] = alt
-			} else {
-				labelsMap[label.Name] = label.Color
-			}
+	return schema
+}
+
+// Stops the import when any card is in a list that is not mapped to a database, listing every such list up front rather
+// than failing on each of their cards
+func checkListMappings(config *config.Config, cards types.CardStream) {
+	open, archived := unmappedLists(config, cards)
+	if len(open) == 0 && len(archived) == 0 {
+		return
+	}
+
+	var problems []string
+	if len(open) > 0 {
+		problems = append(problems, fmt.Sprintf("lists %s are not mapped to a database", strings.Join(open, ", ")))
+	}
+	if len(archived) > 0 {
+		problems = append(problems, fmt.Sprintf(
+			"archived cards in lists %s have no database, so map the lists or set archivedDatabase",
+			strings.Join(archived, ", "),
+		))
+	}
+
+	log.Fatalf("Unable to import: %s in the config\n", strings.Join(problems, "; "))
+}
+
+// Lists of the open cards and of the archived cards that have no database to be imported into
+func unmappedLists(config *config.Config, cards types.CardStream) (open, archived []string) {
+	eachCard(cards, func(card *types.Card) {
+		if config.DatabaseName(card.ParentListName, card.Archived) != "" {
+			return
 		}
+
+		if card.Archived && !contains(card.ParentListName, archived) {
+			archived = append(archived, card.ParentListName)
+		} else if !card.Archived && !contains(card.ParentListName, open) {
+			open = append(open, card.ParentListName)
+		}
+	})
+
+	return
+}
+
+// Adds the list to the unique list names, mapped to the option names used in a single database
+func extractList(config *config.Config, lists []string, listName string) []string {
+	list := config.ListOption(listName)
+	if !contains(list, lists) {
+		lists = append(lists, list)
 	}
 
-	var labels []*types.Label
-	for name, color := range labelsMap {
-		labels = append(labels, &types.Label{name, color})
+	return lists
+}
+
+// Adds the labels of the board or of a card to the unique labels. A label seen again takes the color it was last seen
+// with.
+func extractLabels(config *config.Config, labels, newLabels []*types.Label) []*types.Label {
+	for _, label := range newLabels {
+		color := label.Color
+		if alt, ok := config.Color[label.Color]; ok {
+			color = alt
+		}
+
+		if existing := findLabel(label.Name, labels); existing != nil {
+			existing.Color = color
+		} else {
+			labels = append(labels, &types.Label{Name: label.Name, Color: color})
+		}
 	}
 
 	return labels
 }
 
-func getDatabaseNameIds(notion *notionapi.Client, names []string) *databaseNameIds {
+// Finds the databases with the given names, returning their IDs along with the names that have no database
+func getDatabaseNameIds(notion *notionapi.Client, names []string) (*databaseNameIds, []string) {
 	nameIds := make(databaseNameIds)
 
-	searchResp, _ := notion.Search.Do(context.Background(), &notionapi.SearchRequest{
-		Filter: map[string]string{
-			"value":    "database",
-			"property": "object",
-		},
-	})
+	var cursor notionapi.Cursor
+	for {
+		searchResp, err := notion.Search.Do(context.Background(), &notionapi.SearchRequest{
+			Filter: map[string]string{
+				"value":    "database",
+				"property": "object",
+			},
+			StartCursor: cursor,
+			PageSize:    100,
+		})
+		if err != nil {
+			log.Fatalf("Failed to search for databases: %v\n", err)
+		}
 
-	for _, result := range searchResp.Results {
-		r := result.(*notionapi.Database)
-		title := r.Title[0].Text.Content
-		if contains(title, names) {
-			nameIds[databaseName(title)] = databaseId(r.ID.String())
+		for _, result := range searchResp.Results {
+			r := result.(*notionapi.Database)
+			if len(r.Title) == 0 {
+				continue
+			}
+
+			title := r.Title[0].Text.Content
+			if contains(title, names) {
+				nameIds[databaseName(title)] = databaseId(r.ID.String())
+			}
 		}
+
+		if !searchResp.HasMore {
+			break
+		}
+		cursor = searchResp.NextCursor
 	}
 
+	var missing []string
 	for _, name := range names {
-		if _, ok := nameIds[databaseName(name)]; !ok {
-			log.Fatalf("Unable to find database titled %s to import to\n", name)
+		if _, ok := nameIds[databaseName(name)]; !ok && !contains(name, missing) {
+			missing = append(missing, name)
+		}
+	}
+
+	return &nameIds, missing
+}
+
+// Creates the missing databases under the parent page with the properties needed for importing cards
+func createMissingDatabases(
+	config *config.Config,
+	notion *notionapi.Client,
+	nameIds *databaseNameIds,
+	missing []string,
+	parentPage string,
+	schema *boardSchema,
+) {
+	if len(missing) == 0 {
+		return
+	}
+
+	if parentPage == "" {
+		log.Fatalf(
+			"Unable to find database titled %s to import to. Create it or set a parent page to create it under\n",
+			missing[0],
+		)
+	}
+
+	properties := newDatabaseProperties(config, schema)
+
+	for _, name := range missing {
+		log.Printf("Creating database %s\n", name)
+
+		database, err := notion.Database.Create(context.Background(), &notionapi.DatabaseCreateRequest{
+			Parent: notionapi.Parent{
+				Type:   "page_id",
+				PageID: notionapi.PageID(parsePageId(parentPage)),
+			},
+			Title:      richText(name, noLink),
+			Properties: properties,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create database %s: %v\n", name, err)
 		}
+
+		(*nameIds)[databaseName(name)] = databaseId(database.ID.String())
 	}
+}
 
-	return &nameIds
+// Properties of a database created for importing cards. A database needs a title property on top of the imported ones.
+func newDatabaseProperties(config *config.Config, schema *boardSchema) notionapi.PropertyConfigs {
+	properties := databaseProperties(config, schema)
+	properties["Name"] = titleConfig()
+
+	return properties
 }
@@ -0,0 +1,144 @@
+package notion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+func TestOptionColorsDoNotDependOnOtherOptions(t *testing.T) {
+	alone := colorOptions([]string{"Done"})
+	withOthers := colorOptions([]string{"Backlog", "Doing", "Done"})
+
+	if alone[0].Color != withOthers[2].Color {
+		t.Errorf("Done is %s alone but %s after other lists", alone[0].Color, withOthers[2].Color)
+	}
+}
+
+func TestExtractSchemaListsFollowTheBoard(t *testing.T) {
+	save := &types.Save{Lists: []*types.List{{Name: "Backlog"}, {Name: "Doing"}, {Name: "Done"}}}
+	cards := types.CardSlice{
+		{Id: "1", ParentListName: "Done"},
+		{Id: "2", ParentListName: "Old List"},
+		{Id: "3", ParentListName: "Backlog"},
+	}
+
+	schema := extractSchema(&config.Config{}, save, cards)
+
+	want := []string{"Backlog", "Doing", "Done", "Old List"}
+	if !reflect.DeepEqual(schema.lists, want) {
+		t.Errorf("lists = %v, want %v", schema.lists, want)
+	}
+}
+
+func TestKeepOptionColors(t *testing.T) {
+	database := &notionapi.Database{Properties: notionapi.PropertyConfigs{
+		"List": &notionapi.SelectPropertyConfig{Select: notionapi.Select{Options: []notionapi.Option{
+			{Name: "Doing", Color: notionapi.ColorRed},
+		}}},
+	}}
+	properties := notionapi.PropertyConfigs{
+		"List": selectConfig([]notionapi.Option{
+			{Name: "Doing", Color: notionapi.ColorBlue},
+			{Name: "Done", Color: notionapi.ColorGreen},
+		}),
+	}
+
+	keepOptionColors(database, properties)
+
+	options := properties["List"].(notionapi.SelectPropertyConfig).Select.Options
+	if options[0].Color != "" || options[1].Color != notionapi.ColorGreen {
+		t.Errorf("options = %v, want the existing option without a color and the new one with its color", options)
+	}
+}
+
+func TestUnmappedMembersWithTheSameNameGetSeparateOptions(t *testing.T) {
+	members := []*types.Member{
+		{Id: "1", FullName: "Alex Tan", Username: "alextan"},
+		{Id: "2", FullName: "Alex Tan", Username: "alextan2"},
+		{Id: "3", Username: "sam"},
+		{Id: "4", FullName: "Lee, Jo"},
+	}
+
+	_, unmapped := splitMembers(&config.Config{}, members)
+	var names []string
+	for _, option := range colorOptions(unmapped) {
+		names = append(names, option.Name)
+	}
+
+	want := []string{"Alex Tan (@alextan)", "Alex Tan (@alextan2)", "sam", "Lee Jo"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("options = %v, want %v", names, want)
+	}
+}
+
+func TestColorOptionsSkipRepeatedNames(t *testing.T) {
+	options := colorOptions([]string{"Done", "Doing", "Done"})
+	if len(options) != 2 {
+		t.Errorf("options = %v, want Done and Doing once each", options)
+	}
+}
+
+func TestExtractSchemaLabelsIncludeUnusedBoardLabels(t *testing.T) {
+	save := &types.Save{Labels: []*types.Label{{Name: "Bug", Color: "red"}, {Name: "Unused", Color: "sky"}}}
+	cards := types.CardSlice{{Id: "1", Labels: []*types.Label{{Name: "Bug", Color: "red"}}}}
+
+	schema := extractSchema(&config.Config{Color: map[string]string{"sky": "blue"}}, save, cards)
+
+	var labels []string
+	for _, label := range schema.labels {
+		labels = append(labels, label.Name+":"+label.Color)
+	}
+	want := []string{"Bug:red", "Unused:blue"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+}
+
+func TestExtractSchemaCustomFieldsIncludeUnusedDefinitions(t *testing.T) {
+	save := &types.Save{CustomFields: []*types.CustomFieldDefinition{
+		{Name: "Priority", Type: "list", Options: []*types.Label{{Name: "High", Color: "red"}, {Name: "Low"}}},
+		{Name: "Estimate", Type: "number"},
+	}}
+	cards := types.CardSlice{{Id: "1", CustomFields: []*types.CustomField{{Name: "Priority", Type: "list", Text: "Low"}}}}
+
+	schema := extractSchema(&config.Config{}, save, cards)
+
+	var fields []string
+	for _, field := range schema.customFields {
+		description := field.name + ":" + field.fieldType
+		for _, option := range field.options {
+			description += " " + option.Name
+		}
+		fields = append(fields, description)
+	}
+	want := []string{"Priority:list High Low", "Estimate:number"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("custom fields = %v, want %v", fields, want)
+	}
+}
+
+func TestUnmappedLists(t *testing.T) {
+	cards := types.CardSlice{
+		{Id: "1", ParentListName: "Doing"},
+		{Id: "2", ParentListName: "Ideas"},
+		{Id: "3", ParentListName: "Old", Archived: true},
+		{Id: "4", ParentListName: "Doing", Archived: true},
+		{Id: "5", ParentListName: "Old", Archived: true},
+	}
+
+	config := &config.Config{Database: map[string]string{"Doing": "Tasks"}}
+	open, archived := unmappedLists(config, cards)
+	if !reflect.DeepEqual(open, []string{"Ideas"}) || !reflect.DeepEqual(archived, []string{"Old"}) {
+		t.Errorf("unmappedLists = %v, %v, want [Ideas], [Old]", open, archived)
+	}
+
+	config.ArchivedDatabase = "Archive"
+	open, archived = unmappedLists(config, cards)
+	if !reflect.DeepEqual(open, []string{"Ideas"}) || archived != nil {
+		t.Errorf("unmappedLists with an archived database = %v, %v, want [Ideas], []", open, archived)
+	}
+}
@@ -0,0 +1,209 @@
+package notion
+
+import (
+	"log"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"golang.org/x/net/context"
+)
+
+// Name of the database property holding the Trello card ID that a page was imported from
+const cardIdProperty = "Trello ID"
+
+// Name of the database property holding the short link of the card, which links to the card are made with
+const shortLinkProperty = "Trello Short Link"
+
+// Notion accepts at most 100 blocks in a single create or append request
+const maxChildrenPerRequest = 100
+
+// Types for pages that have already been imported
+type (
+	cardId       string
+	existingPage struct {
+		id       notionapi.PageID
+		database databaseId
+		// Short link of the card, which is empty for pages imported before short links were stored
+		shortLink string
+	}
+	existingPages map[cardId]existingPage
+)
+
+// Everything written to Notion for a card
+type cardPage struct {
+	database   databaseId
+	properties notionapi.Properties
+	children   []notionapi.Block
+	cover      *notionapi.Image
+	icon       *notionapi.Icon
+}
+
+// Finds the pages previously imported into the given databases, keyed by the Trello card ID they were imported from
+func getExistingPages(notion *notionapi.Client, nameIds *databaseNameIds) *existingPages {
+	log.Printf("Finding previously imported cards\n")
+
+	pages := make(existingPages)
+
+	for name, id := range *nameIds {
+		var cursor notionapi.Cursor
+
+		for {
+			resp, err := notion.Database.Query(context.Background(), notionapi.DatabaseID(id), &notionapi.DatabaseQueryRequest{
+				PropertyFilter: &notionapi.PropertyFilter{
+					Property: cardIdProperty,
+					Text:     &notionapi.TextFilterCondition{IsNotEmpty: true},
+				},
+				StartCursor: cursor,
+				PageSize:    100,
+			})
+			if err != nil {
+				log.Fatalf("Failed to query existing pages in %s: %v\n", name, err)
+			}
+
+			for _, page := range resp.Results {
+				if trelloId := pageText(page, cardIdProperty); trelloId != "" {
+					pages[cardId(trelloId)] = existingPage{notionapi.PageID(page.ID), id, pageText(page, shortLinkProperty)}
+				}
+			}
+
+			if !resp.HasMore {
+				break
+			}
+			cursor = resp.NextCursor
+		}
+	}
+
+	log.Printf("Found %d previously imported cards\n", len(pages))
+
+	return &pages
+}
+
+// Plain text of a rich text property of the page
+func pageText(page notionapi.Page, name string) string {
+	property, ok := page.Properties[name].(*notionapi.RichTextProperty)
+	if !ok {
+		return ""
+	}
+
+	var text strings.Builder
+	for _, t := range property.RichText {
+		text.WriteString(t.Text.Content)
+	}
+
+	return text.String()
+}
+
+// Creates a page in its database. Children past the per-request limit are appended after the page is created. The
+// cover and icon cannot be given when creating a page so they are set straight after.
+func createPage(notion *notionapi.Client, page *cardPage) (notionapi.PageID, error) {
+	first, rest := splitChildren(page.children)
+
+	created, err := notion.Page.Create(context.Background(), &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			DatabaseID: notionapi.DatabaseID(page.database),
+		},
+		Properties: page.properties,
+		Children:   first,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	id := notionapi.PageID(created.ID)
+
+	if page.cover != nil || page.icon != nil {
+		_, err = notion.Page.Update(context.Background(), id, &notionapi.PageUpdateRequest{
+			Properties: notionapi.Properties{},
+			Cover:      page.cover,
+			Icon:       page.icon,
+		})
+		if err != nil {
+			return id, err
+		}
+	}
+
+	return id, appendChildren(notion, notionapi.BlockID(id), rest)
+}
+
+// Replaces the properties, cover, icon and children of an existing page
+func updatePage(notion *notionapi.Client, id notionapi.PageID, page *cardPage) error {
+	_, err := notion.Page.Update(context.Background(), id, &notionapi.PageUpdateRequest{
+		Properties: page.properties,
+		Cover:      page.cover,
+		Icon:       page.icon,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = clearChildren(notion, notionapi.BlockID(id)); err != nil {
+		return err
+	}
+
+	return appendChildren(notion, notionapi.BlockID(id), page.children)
+}
+
+func archivePage(notion *notionapi.Client, id notionapi.PageID) error {
+	_, err := notion.Page.Update(context.Background(), id, &notionapi.PageUpdateRequest{
+		Properties: notionapi.Properties{},
+		Archived:   true,
+	})
+
+	return err
+}
+
+func clearChildren(notion *notionapi.Client, id notionapi.BlockID) error {
+	var blockIds []notionapi.BlockID
+	var cursor string
+
+	for {
+		resp, err := notion.Block.GetChildren(context.Background(), id, &notionapi.Pagination{
+			StartCursor: notionapi.Cursor(cursor),
+			PageSize:    100,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, block := range resp.Results {
+			blockIds = append(blockIds, block.GetID())
+		}
+
+		if !resp.HasMore {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	for _, blockId := range blockIds {
+		if _, err := notion.Block.Delete(context.Background(), blockId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appendChildren(notion *notionapi.Client, id notionapi.BlockID, children []notionapi.Block) error {
+	for len(children) > 0 {
+		var batch []notionapi.Block
+		batch, children = splitChildren(children)
+
+		_, err := notion.Block.AppendChildren(context.Background(), id, &notionapi.AppendBlockChildrenRequest{
+			Children: batch,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func splitChildren(children []notionapi.Block) (first, rest []notionapi.Block) {
+	if len(children) <= maxChildrenPerRequest {
+		return children, nil
+	}
+
+	return children[:maxChildrenPerRequest], children[maxChildrenPerRequest:]
+}
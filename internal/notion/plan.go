@@ -0,0 +1,365 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/woojiahao/baleen/internal/config"
+	"github.com/woojiahao/baleen/internal/types"
+	"golang.org/x/net/context"
+)
+
+// Actions that an import takes for a card
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	// The card was imported into a different database, so the old page is archived and a new one is created
+	actionMove = "move"
+	actionSkip = "skip"
+)
+
+// Changes that an import would make to a database's schema
+type databasePlan struct {
+	Name              string   `json:"name"`
+	Id                string   `json:"id"`
+	Created           bool     `json:"created"`
+	AddedProperties   []string `json:"addedProperties"`
+	ChangedProperties []string `json:"changedProperties"`
+	AddedLabels       []string `json:"addedLabels"`
+	AddedOptions      []string `json:"addedOptions"`
+	// Either the notionapi.DatabaseUpdateRequest or, for a database that is created, the notionapi.DatabaseCreateRequest
+	Request interface{} `json:"request"`
+}
+
+// Request that an import would send for a card
+type pagePlan struct {
+	Action   string                       `json:"action"`
+	CardId   string                       `json:"cardId"`
+	Name     string                       `json:"name"`
+	Database string                       `json:"database"`
+	Request  *notionapi.PageCreateRequest `json:"request,omitempty"`
+	// Set on the page straight after it is created, as the create request cannot hold them
+	Cover *notionapi.Image `json:"cover,omitempty"`
+	Icon  *notionapi.Icon  `json:"icon,omitempty"`
+}
+
+// Card whose links to other cards would be pointed at their pages once every card is imported
+type linkPlan struct {
+	CardId string `json:"cardId"`
+	Name   string `json:"name"`
+	// Short links of the cards that the card links to
+	LinkedCards []string `json:"linkedCards"`
+}
+
+type importPlan struct {
+	Databases []*databasePlan `json:"databases"`
+	Pages     []*pagePlan     `json:"pages"`
+	Links     []*linkPlan     `json:"links"`
+}
+
+// Works out every change that importing the cards would make and prints a summary of them. Only reads from Notion.
+func planImport(
+	config *config.Config,
+	notion *notionapi.Client,
+	nameIds *databaseNameIds,
+	missing []string,
+	parentPage string,
+	schema *boardSchema,
+	cards types.CardStream,
+	options ImportOptions,
+) {
+	log.Printf("Dry run: planning import without making any changes to Notion\n")
+
+	plan := &importPlan{}
+	imported := make(databaseNameIds)
+	idNames := make(map[databaseId]databaseName)
+
+	for name, id := range *nameIds {
+		database, err := notion.Database.Get(context.Background(), notionapi.DatabaseID(id))
+		if err != nil {
+			log.Fatalf("Failed to get database %s: %v\n", name, err)
+		}
+
+		properties := databaseProperties(config, schema)
+		if config.RelatedCards {
+			properties[relatedCardsProperty] = relationConfig(notionapi.DatabaseID(id))
+		}
+
+		keepOptionColors(database, properties)
+		plan.Databases = append(plan.Databases, planDatabase(string(name), database, properties))
+		idNames[id] = name
+
+		// Only databases that have been imported into before can be searched for existing pages
+		if _, ok := database.Properties[cardIdProperty]; ok {
+			imported[name] = id
+		}
+	}
+
+	if len(missing) > 0 && parentPage == "" {
+		log.Fatalf(
+			"Unable to find database titled %s to import to. Create it or set a parent page to create it under\n",
+			missing[0],
+		)
+	}
+
+	// Databases that don't exist yet are given a placeholder ID so that the pages can be planned against them
+	planned := make(databaseNameIds)
+	for name, id := range *nameIds {
+		planned[name] = id
+	}
+
+	newProperties := newDatabaseProperties(config, schema)
+	for _, name := range missing {
+		id := databaseId("new database " + name)
+		planned[databaseName(name)] = id
+		idNames[id] = databaseName(name)
+		plan.Databases = append(plan.Databases, planNewDatabase(name, parentPage, newProperties))
+	}
+	nameIds = &planned
+
+	sort.Slice(plan.Databases, func(i, j int) bool {
+		return plan.Databases[i].Name < plan.Databases[j].Name
+	})
+
+	existing := getExistingPages(notion, &imported)
+
+	var journal *journal
+	if options.Resume && options.JournalPath != "" {
+		journal = readJournal(options.JournalPath)
+	}
+
+	eachCard(cards, func(card *types.Card) {
+		// Attachments are not uploaded during a dry run so they are planned as loaded from Trello
+		built := buildPage(config, nameIds, nil, card)
+		database := built.database
+
+		page := &pagePlan{
+			CardId:   card.Id,
+			Name:     card.Name,
+			Database: string(idNames[database]),
+			Request: &notionapi.PageCreateRequest{
+				Parent:     notionapi.Parent{DatabaseID: notionapi.DatabaseID(database)},
+				Properties: built.properties,
+				Children:   built.children,
+			},
+			Cover: built.cover,
+			Icon:  built.icon,
+		}
+
+		existingPage, ok := (*existing)[cardId(card.Id)]
+		switch {
+		case journal.isImported(card.Id):
+			page.Action = actionSkip
+			page.Request, page.Cover, page.Icon = nil, nil, nil
+		case !ok:
+			page.Action = actionCreate
+		case existingPage.database == database:
+			page.Action = actionUpdate
+		default:
+			page.Action = actionMove
+		}
+
+		plan.Pages = append(plan.Pages, page)
+	})
+
+	plan.Links = planLinks(existing, cards)
+
+	printPlan(plan)
+
+	if options.PlanPath != "" {
+		data, _ := json.MarshalIndent(plan, "", "  ")
+		if err := ioutil.WriteFile(options.PlanPath, data, 0644); err != nil {
+			log.Fatalf("Failed to write plan to %s: %v\n", options.PlanPath, err)
+		}
+
+		log.Printf("Wrote the full plan to %s\n", options.PlanPath)
+	}
+}
+
+// Plans the pass that points the links between cards at their pages, assuming that every card is imported. Cards can
+// also link to the pages already in the databases.
+func planLinks(existing *existingPages, cards types.CardStream) []*linkPlan {
+	links, _ := existingLinks(existing, nil)
+	eachCard(cards, func(card *types.Card) {
+		if card.ShortLink != "" {
+			links[card.ShortLink] = existingPage{}
+		}
+	})
+
+	plans := []*linkPlan{}
+	eachCard(cards, func(card *types.Card) {
+		if linked := links.linked(card); len(linked) > 0 {
+			plans = append(plans, &linkPlan{CardId: card.Id, Name: card.Name, LinkedCards: linked})
+		}
+	})
+
+	return plans
+}
+
+// Compares the properties that the import needs against the database's current schema
+func planDatabase(name string, database *notionapi.Database, properties notionapi.PropertyConfigs) *databasePlan {
+	plan := &databasePlan{
+		Name:    name,
+		Id:      database.ID.String(),
+		Request: &notionapi.DatabaseUpdateRequest{Properties: properties},
+	}
+
+	for property, config := range properties {
+		current, ok := database.Properties[property]
+		if !ok {
+			plan.AddedProperties = append(plan.AddedProperties, property)
+			continue
+		}
+
+		if current.GetType() != config.GetType() {
+			plan.ChangedProperties = append(
+				plan.ChangedProperties,
+				fmt.Sprintf("%s (%s to %s)", property, current.GetType(), config.GetType()),
+			)
+			continue
+		}
+
+		switch options := config.(type) {
+		case notionapi.MultiSelectPropertyConfig:
+			var currentOptions []notionapi.Option
+			if currentLabels, ok := current.(*notionapi.MultiSelectPropertyConfig); ok {
+				currentOptions = currentLabels.MultiSelect.Options
+			}
+			added := newOptions(currentOptions, options.MultiSelect.Options)
+			if property == "Labels" {
+				plan.AddedLabels = append(plan.AddedLabels, added...)
+			} else {
+				for _, option := range added {
+					plan.AddedOptions = append(plan.AddedOptions, fmt.Sprintf("%s: %s", property, option))
+				}
+			}
+		case notionapi.SelectPropertyConfig:
+			var currentOptions []notionapi.Option
+			if currentSelect, ok := current.(*notionapi.SelectPropertyConfig); ok {
+				currentOptions = currentSelect.Select.Options
+			}
+			for _, option := range newOptions(currentOptions, options.Select.Options) {
+				plan.AddedOptions = append(plan.AddedOptions, fmt.Sprintf("%s: %s", property, option))
+			}
+		}
+	}
+
+	sort.Strings(plan.AddedProperties)
+	sort.Strings(plan.ChangedProperties)
+	sort.Strings(plan.AddedLabels)
+	sort.Strings(plan.AddedOptions)
+
+	return plan
+}
+
+func planNewDatabase(name, parentPage string, properties notionapi.PropertyConfigs) *databasePlan {
+	plan := &databasePlan{
+		Name:    name,
+		Created: true,
+		Request: &notionapi.DatabaseCreateRequest{
+			Parent: notionapi.Parent{
+				Type:   "page_id",
+				PageID: notionapi.PageID(parsePageId(parentPage)),
+			},
+			Title:      richText(name, noLink),
+			Properties: properties,
+		},
+	}
+
+	for property := range properties {
+		plan.AddedProperties = append(plan.AddedProperties, property)
+	}
+	sort.Strings(plan.AddedProperties)
+
+	return plan
+}
+
+func newOptions(current, options []notionapi.Option) []string {
+	existing := make(map[string]bool)
+	for _, option := range current {
+		existing[option.Name] = true
+	}
+
+	var added []string
+	for _, option := range options {
+		if !existing[option.Name] {
+			added = append(added, option.Name)
+		}
+	}
+
+	return added
+}
+
+func printPlan(plan *importPlan) {
+	fmt.Println("Dry run, no changes have been made to Notion")
+	fmt.Println()
+	fmt.Println("Databases:")
+
+	for _, database := range plan.Databases {
+		var changes []string
+		if database.Created {
+			changes = append(changes, "create database")
+		}
+		if len(database.AddedProperties) > 0 {
+			changes = append(changes, "add properties "+strings.Join(database.AddedProperties, ", "))
+		}
+		if len(database.ChangedProperties) > 0 {
+			changes = append(changes, "change properties "+strings.Join(database.ChangedProperties, ", "))
+		}
+		if len(database.AddedLabels) > 0 {
+			changes = append(changes, "add labels "+strings.Join(database.AddedLabels, ", "))
+		}
+		if len(database.AddedOptions) > 0 {
+			changes = append(changes, "add options "+strings.Join(database.AddedOptions, ", "))
+		}
+		if len(changes) == 0 {
+			changes = append(changes, "no schema changes")
+		}
+
+		if database.Created {
+			fmt.Printf("  %s (new): %s\n", database.Name, strings.Join(changes, "; "))
+		} else {
+			fmt.Printf("  %s (%s): %s\n", database.Name, database.Id, strings.Join(changes, "; "))
+		}
+	}
+
+	actions := []string{actionCreate, actionUpdate, actionMove, actionSkip}
+	counts := make(map[string]map[string]int)
+	totals := make(map[string]int)
+
+	for _, page := range plan.Pages {
+		if counts[page.Database] == nil {
+			counts[page.Database] = make(map[string]int)
+		}
+
+		counts[page.Database][page.Action]++
+		totals[page.Action]++
+	}
+
+	fmt.Println()
+	fmt.Println("Pages:")
+
+	for _, database := range plan.Databases {
+		fmt.Printf("  %s: %s\n", database.Name, formatCounts(actions, counts[database.Name]))
+	}
+
+	fmt.Println()
+	fmt.Printf("Total: %s\n", formatCounts(actions, totals))
+
+	fmt.Println()
+	fmt.Printf("Links: %d cards link to other cards and would have those links pointed at their pages\n", len(plan.Links))
+}
+
+func formatCounts(actions []string, counts map[string]int) string {
+	var parts []string
+	for _, action := range actions {
+		parts = append(parts, fmt.Sprintf("%d to %s", counts[action], action))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,220 @@
+package notion
+
+import (
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Notion allows an average of three requests per second per integration
+	DefaultRequestsPerSecond = 3.0
+
+	maxRetries    = 5
+	baseBackoff   = time.Second
+	maxBackoff    = 30 * time.Second
+	maxJitter     = time.Second
+	headerTimeout = 60 * time.Second
+	dialTimeout   = 30 * time.Second
+)
+
+// Transport shared by every call to the Notion API. Requests are spaced out by a token bucket and requests that fail
+// with a retryable error (429, 409, or a 5xx or timeout on an idempotent request) are retried with a jittered
+// exponential backoff. A 429 with a Retry-After header pauses every request until the wait is over. Any other error,
+// such as a 400 validation error, is returned straight away.
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *rate.Limiter
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+	// Source of the jitter added to waits, local to the transport so that the global source is left alone
+	random *rand.Rand
+}
+
+// Creates the HTTP client used by the Notion client, limited to requestsPerSecond
+func newRateLimitedClient(requestsPerSecond float64) *http.Client {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = DefaultRequestsPerSecond
+	}
+
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: dialTimeout}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: headerTimeout,
+	}
+
+	return &http.Client{
+		Transport: &rateLimitedTransport{
+			base:    base,
+			limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), 1),
+			random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		},
+	}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req); err != nil {
+			return nil, err
+		}
+
+		attemptReq, err := rewind(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := t.base.RoundTrip(attemptReq)
+		if !isRetryable(req, res, err) || attempt >= maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return res, err
+		}
+
+		wait := t.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(res); ok {
+			wait = retryAfter + t.jitter(maxJitter)
+			t.block(wait)
+		}
+
+		if err != nil {
+			log.Printf("Notion request %s %s failed: %v. Retrying in %v\n", req.Method, req.URL.Path, err, wait.Round(time.Millisecond))
+		} else {
+			log.Printf("Notion request %s %s returned %d. Retrying in %v\n", req.Method, req.URL.Path, res.StatusCode, wait.Round(time.Millisecond))
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// Waits for any Retry-After pause to end and then for a token from the bucket
+func (t *rateLimitedTransport) wait(req *http.Request) error {
+	t.mu.Lock()
+	pause := time.Until(t.blockedUntil)
+	t.mu.Unlock()
+
+	if pause > 0 {
+		select {
+		case <-time.After(pause):
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+
+	return t.limiter.Wait(req.Context())
+}
+
+func (t *rateLimitedTransport) block(wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(wait); until.After(t.blockedUntil) {
+		t.blockedUntil = until
+	}
+}
+
+// The body of a request can only be read once so every retry sends a fresh copy of it
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.GetBody == nil {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	clone := req.Clone(req.Context())
+	clone.Body = body
+
+	return clone, nil
+}
+
+// Notion rejects a 429 or 409 before making any change, so those are always retried. A timeout or a 5xx may come after
+// the change was made, so those are only retried when sending the request again cannot make the change twice.
+func isRetryable(req *http.Request, res *http.Response, err error) bool {
+	if err != nil {
+		netErr, ok := err.(net.Error)
+		return ((ok && netErr.Timeout()) || err == io.ErrUnexpectedEOF) && isIdempotent(req)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusConflict:
+		return true
+	default:
+		return res.StatusCode >= 500 && isIdempotent(req)
+	}
+}
+
+// Whether sending the request twice has the same effect as sending it once. Creating a page or a comment and appending
+// blocks are not, as each attempt adds another one.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	case http.MethodPatch:
+		return !strings.HasSuffix(req.URL.Path, "/children")
+	case http.MethodPost:
+		// Queries and searches only read
+		return strings.HasSuffix(req.URL.Path, "/query") || req.URL.Path == "/v1/search"
+	default:
+		return false
+	}
+}
+
+// Reads the Retry-After header, given either in seconds or as an HTTP date
+func parseRetryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	header := res.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
+
+// Exponential backoff capped at maxBackoff, with up to half of the wait randomised so that parallel requests spread out
+func (t *rateLimitedTransport) backoff(attempt int) time.Duration {
+	ceiling := time.Duration(math.Min(float64(maxBackoff), float64(baseBackoff)*math.Pow(2, float64(attempt))))
+	return ceiling/2 + t.jitter(ceiling/2)
+}
+
+// Random wait of up to max. The source is shared by every request, which a rand.Rand is not safe for on its own.
+func (t *rateLimitedTransport) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return time.Duration(t.random.Int63n(int64(max)))
+}
@@ -0,0 +1,69 @@
+package notion
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		status int
+		want   bool
+	}{
+		{http.MethodPost, "/v1/pages", http.StatusTooManyRequests, true},
+		{http.MethodPost, "/v1/pages", http.StatusConflict, true},
+		{http.MethodPost, "/v1/pages", http.StatusBadGateway, false},
+		{http.MethodPost, "/v1/comments", http.StatusInternalServerError, false},
+		{http.MethodPatch, "/v1/blocks/id/children", http.StatusServiceUnavailable, false},
+		{http.MethodPatch, "/v1/pages/id", http.StatusServiceUnavailable, true},
+		{http.MethodGet, "/v1/databases/id", http.StatusInternalServerError, true},
+		{http.MethodDelete, "/v1/blocks/id", http.StatusBadGateway, true},
+		{http.MethodPost, "/v1/databases/id/query", http.StatusBadGateway, true},
+		{http.MethodPost, "/v1/search", http.StatusGatewayTimeout, true},
+		{http.MethodGet, "/v1/databases/id", http.StatusBadRequest, false},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, "https://api.notion.com"+test.path, nil)
+		res := &http.Response{StatusCode: test.status}
+
+		if got := isRetryable(req, res, nil); got != test.want {
+			t.Errorf("isRetryable(%s %s, %d) = %v, want %v", test.method, test.path, test.status, got, test.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryableTimeout(t *testing.T) {
+	create, _ := http.NewRequest(http.MethodPost, "https://api.notion.com/v1/pages", nil)
+	if isRetryable(create, nil, timeoutError{}) {
+		t.Errorf("a page create that timed out may have created the page, so it must not be retried")
+	}
+
+	get, _ := http.NewRequest(http.MethodGet, "https://api.notion.com/v1/pages/id", nil)
+	if !isRetryable(get, nil, timeoutError{}) {
+		t.Errorf("a get that timed out should be retried")
+	}
+}
+
+func TestBackoffStaysWithinItsCeiling(t *testing.T) {
+	transport := newRateLimitedClient(DefaultRequestsPerSecond).Transport.(*rateLimitedTransport)
+
+	for attempt := 0; attempt <= maxRetries+3; attempt++ {
+		ceiling := baseBackoff << attempt
+		if ceiling > maxBackoff {
+			ceiling = maxBackoff
+		}
+
+		if wait := transport.backoff(attempt); wait < ceiling/2 || wait >= ceiling {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, wait, ceiling/2, ceiling)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package notion
 
 import (
+	"hash/fnv"
 	"math"
+	"regexp"
 	"time"
 
 	na "github.com/jomei/notionapi"
@@ -9,6 +11,12 @@ import (
 
 const noLink = "null"
 
+func titleConfig() na.TitlePropertyConfig {
+	return na.TitlePropertyConfig{
+		Type: na.PropertyConfigTypeTitle,
+	}
+}
+
 func richTextConfig() na.RichTextPropertyConfig {
 	return na.RichTextPropertyConfig{
 		Type: na.PropertyConfigTypeRichText,
@@ -27,6 +35,21 @@ func dateConfig() na.DatePropertyConfig {
 	}
 }
 
+func checkboxConfig() na.CheckboxPropertyConfig {
+	return na.CheckboxPropertyConfig{
+		Type: na.PropertyConfigTypeCheckbox,
+	}
+}
+
+func selectConfig(options []na.Option) na.SelectPropertyConfig {
+	return na.SelectPropertyConfig{
+		Type: na.PropertyConfigTypeSelect,
+		Select: na.Select{
+			Options: options,
+		},
+	}
+}
+
 func multiSelectConfig(options []na.Option) na.MultiSelectPropertyConfig {
 	return na.MultiSelectPropertyConfig{
 		Type: na.PropertyConfigTypeMultiSelect,
@@ -36,6 +59,56 @@ func multiSelectConfig(options []na.Option) na.MultiSelectPropertyConfig {
 	}
 }
 
+// Number property config that nests the format as Notion expects. notionapi.NumberPropertyConfig sends the format
+// alongside the type, which Notion rejects.
+type numberPropertyConfig struct {
+	Type   na.PropertyConfigType `json:"type"`
+	Number numberFormat          `json:"number"`
+}
+
+type numberFormat struct {
+	Format na.FormatType `json:"format"`
+}
+
+func (p numberPropertyConfig) GetType() na.PropertyConfigType {
+	return p.Type
+}
+
+func numberConfig() numberPropertyConfig {
+	return numberPropertyConfig{
+		Type:   na.PropertyConfigTypeNumber,
+		Number: numberFormat{na.FormatNumber},
+	}
+}
+
+// Relation property config that only holds the database. notionapi.RelationConfig sends the empty synced property as
+// well, which Notion rejects.
+type relationPropertyConfig struct {
+	Type     na.PropertyConfigType `json:"type"`
+	Relation relationDatabase      `json:"relation"`
+}
+
+type relationDatabase struct {
+	DatabaseID na.DatabaseID `json:"database_id"`
+}
+
+func (p relationPropertyConfig) GetType() na.PropertyConfigType {
+	return p.Type
+}
+
+func relationConfig(database na.DatabaseID) relationPropertyConfig {
+	return relationPropertyConfig{
+		Type:     na.PropertyConfigTypeRelation,
+		Relation: relationDatabase{database},
+	}
+}
+
+func peopleConfig() na.PeoplePropertyConfig {
+	return na.PeoplePropertyConfig{
+		Type: na.PropertyConfigTypePeople,
+	}
+}
+
 func titleProperty(title string) na.TitleProperty {
 	return na.TitleProperty{
 		Type:  na.PropertyTypeTitle,
@@ -66,6 +139,54 @@ func dateProperty(date *time.Time) na.DateProperty {
 	}
 }
 
+// Date range from start to end. If there is no start, the range collapses to a single date at end
+func dateRangeProperty(start, end *time.Time) na.DateProperty {
+	if start == nil || !start.Before(*end) {
+		return dateProperty(end)
+	}
+
+	return na.DateProperty{
+		Type: na.PropertyTypeDate,
+		Date: na.DateObject{
+			Start: (*na.Date)(start),
+			End:   (*na.Date)(end),
+		},
+	}
+}
+
+func numberProperty(number float64) na.NumberProperty {
+	return na.NumberProperty{
+		Type:   na.PropertyTypeNumber,
+		Number: number,
+	}
+}
+
+func relationProperty(ids []na.PageID) na.RelationProperty {
+	relations := []na.Relation{}
+	for _, id := range ids {
+		relations = append(relations, na.Relation{ID: id})
+	}
+
+	return na.RelationProperty{
+		Type:     na.PropertyTypeRelation,
+		Relation: relations,
+	}
+}
+
+func checkboxProperty(checked bool) na.CheckboxProperty {
+	return na.CheckboxProperty{
+		Type:     na.PropertyTypeCheckbox,
+		Checkbox: checked,
+	}
+}
+
+func selectProperty(name string) na.SelectProperty {
+	return na.SelectProperty{
+		Type:   na.PropertyTypeSelect,
+		Select: na.Option{Name: name},
+	}
+}
+
 func multiSelectProperty(options []na.Option) na.MultiSelectProperty {
 	return na.MultiSelectProperty{
 		Type:        na.PropertyTypeMultiSelect,
@@ -73,6 +194,44 @@ func multiSelectProperty(options []na.Option) na.MultiSelectProperty {
 	}
 }
 
+// Options that are only given by their name, for setting options that already exist in the database
+func nameOptions(names []string) []na.Option {
+	var options []na.Option
+	for _, name := range names {
+		options = append(options, na.Option{Name: name})
+	}
+
+	return options
+}
+
+// People property that only holds the IDs of the users. notionapi.PeopleProperty sends every empty field of a user as
+// well, which Notion rejects.
+type userIdsProperty struct {
+	Type   na.PropertyType `json:"type"`
+	People []userId        `json:"people"`
+}
+
+type userId struct {
+	Object na.ObjectType `json:"object"`
+	ID     string        `json:"id"`
+}
+
+func (p userIdsProperty) GetType() na.PropertyType {
+	return p.Type
+}
+
+func peopleProperty(ids []string) userIdsProperty {
+	people := []userId{}
+	for _, id := range ids {
+		people = append(people, userId{na.ObjectTypeUser, id})
+	}
+
+	return userIdsProperty{
+		Type:   na.PropertyTypePeople,
+		People: people,
+	}
+}
+
 func paragraph(text string) na.ParagraphBlock {
 	return na.ParagraphBlock{
 		BasicBlock: na.BasicBlock{
@@ -95,22 +254,149 @@ func heading1(title string) na.Heading1Block {
 	}
 }
 
-func linkBlocks(items map[string]string) []na.BulletedListItemBlock {
-	var blocks []na.BulletedListItemBlock
+func heading2(title string) na.Heading2Block {
+	return na.Heading2Block{
+		BasicBlock: basicBlock(na.BlockTypeHeading2),
+		Heading2: na.Heading{
+			Text: richText(title, noLink),
+		},
+	}
+}
 
-	for name, link := range items {
-		block := na.BulletedListItemBlock{
-			BasicBlock: basicBlock(na.BlockTypeBulletedListItem),
-			BulletedListItem: na.ListItem{
-				Text:     richText(string(name), string(link)),
-				Children: []na.Block{},
-			},
+func toDo(text string, checked bool) na.ToDoBlock {
+	return na.ToDoBlock{
+		BasicBlock: basicBlock(na.BlockTypeToDo),
+		ToDo: na.ToDo{
+			Text:     richText(text, noLink),
+			Checked:  checked,
+			Children: []na.Block{},
+		},
+	}
+}
+
+// Colors given to the list options so that the columns of a board view stand out from each other
+var optionColors = []na.Color{
+	na.ColorGray,
+	na.ColorBrown,
+	na.ColorOrange,
+	na.ColorYellow,
+	na.ColorGreen,
+	na.ColorBlue,
+	na.ColorPurple,
+	na.ColorPink,
+	na.ColorRed,
+}
+
+// Options with a color picked from their name. Names that appear more than once only get a single option, as Notion
+// rejects options with the same name.
+func colorOptions(names []string) []na.Option {
+	var options []na.Option
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
 		}
+		seen[name] = true
+
+		options = append(options, na.Option{
+			Name:  name,
+			Color: optionColor(name),
+		})
+	}
+
+	return options
+}
+
+// Color of an option picked from its name. Notion rejects changes to the color of an existing option, so an option
+// must get the same color on every import whatever other options there are.
+func optionColor(name string) na.Color {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+
+	return optionColors[hash.Sum32()%uint32(len(optionColors))]
+}
+
+func linkBlock(name, link string) na.BulletedListItemBlock {
+	return na.BulletedListItemBlock{
+		BasicBlock: basicBlock(na.BlockTypeBulletedListItem),
+		BulletedListItem: na.ListItem{
+			Text:     richText(name, link),
+			Children: []na.Block{},
+		},
+	}
+}
+
+func imageBlock(caption, url string) na.ImageBlock {
+	return na.ImageBlock{
+		BasicBlock: basicBlock(na.BlockTypeImage),
+		Image: na.Image{
+			Caption:  richText(caption, noLink),
+			Type:     "external",
+			External: &na.FileObject{URL: url},
+		},
+	}
+}
+
+func bookmarkBlock(caption, url string) na.BookmarkBlock {
+	return na.BookmarkBlock{
+		BasicBlock: basicBlock(na.BlockTypeBookmark),
+		Bookmark: na.Bookmark{
+			Caption: captionText(caption),
+			URL:     url,
+		},
+	}
+}
 
-		blocks = append(blocks, block)
+func embedBlock(caption, url string) na.EmbedBlock {
+	return na.EmbedBlock{
+		BasicBlock: basicBlock(na.BlockTypeEmbed),
+		Embed: na.Embed{
+			Caption: captionText(caption),
+			URL:     url,
+		},
 	}
+}
 
-	return blocks
+func videoBlock(caption, url string) na.VideoBlock {
+	return na.VideoBlock{
+		BasicBlock: basicBlock(na.BlockTypeVideo),
+		Video: na.Video{
+			Caption:  captionText(caption),
+			Type:     "external",
+			External: &na.FileObject{URL: url},
+		},
+	}
+}
+
+// Captions are left out when empty as richText always returns at least one text
+func captionText(caption string) []na.RichText {
+	if caption == "" {
+		return nil
+	}
+
+	return richText(caption, noLink)
+}
+
+func pdfBlock(caption, url string) na.PdfBlock {
+	return na.PdfBlock{
+		BasicBlock: basicBlock(na.BlockTypePdf),
+		Pdf: na.Pdf{
+			Caption:  richText(caption, noLink),
+			Type:     "external",
+			External: &na.FileObject{URL: url},
+		},
+	}
+}
+
+func externalFileBlock(caption, url string) na.FileBlock {
+	return na.FileBlock{
+		BasicBlock: basicBlock(na.BlockTypeFile),
+		File: na.BlockFile{
+			Caption:  richText(caption, noLink),
+			Type:     "external",
+			External: &na.FileObject{URL: url},
+		},
+	}
 }
 
 func basicBlock(t na.BlockType) na.BasicBlock {
@@ -169,6 +455,19 @@ func contains(s string, arr []string) bool {
 	return false
 }
 
+var pageIdPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}`)
+
+// Extracts the page ID from either a page ID or the URL of a Notion page
+func parsePageId(page string) string {
+	// Page URLs end with the ID, which may be preceded by a title containing other hex characters
+	ids := pageIdPattern.FindAllString(page, -1)
+	if len(ids) == 0 {
+		return page
+	}
+
+	return ids[len(ids)-1]
+}
+
 func chunkEvery(content string, n int) []string {
 	totalChunks := int(math.Ceil(float64(len(content)) / float64(n)))
 	chunks := []string{}
@@ -0,0 +1,183 @@
+package trello
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	t "github.com/adlio/trello"
+	"github.com/woojiahao/baleen/internal/env"
+)
+
+var (
+	// URL of a board, capturing its short link
+	boardUrlPattern = regexp.MustCompile(`^https?://(?:www\.)?trello\.com/b/([A-Za-z0-9]+)`)
+	boardIdPattern  = regexp.MustCompile(`^[0-9a-fA-F]{24}$`)
+	// Short links look like short names so a board is only looked up by its short link when one with it exists
+	shortLinkPattern = regexp.MustCompile(`^[A-Za-z0-9]{8}$`)
+)
+
+// Finds the board given by its ID, short link, URL or exact name. A name that matches several boards is rejected with
+// the matching boards listed, rather than picking one of them.
+func getBoard(client *t.Client, board string) *t.Board {
+	if match := boardUrlPattern.FindStringSubmatch(board); match != nil {
+		return getBoardById(client, match[1])
+	}
+
+	if boardIdPattern.MatchString(board) {
+		return getBoardById(client, board)
+	}
+
+	if shortLinkPattern.MatchString(board) {
+		found, err := client.GetBoard(board)
+		if err == nil {
+			return found
+		}
+		if !t.IsNotFound(err) && !isInvalidId(err) {
+			log.Fatalf("Failed to get board %s: %v\n", board, err)
+		}
+	}
+
+	return getBoardByName(client, board)
+}
+
+// ID of the board given by its ID, short link, URL or exact name
+func BoardId(boardName, envPath string) string {
+	env := env.New(envPath)
+	client := t.NewClient(env.TrelloKey, env.TrelloToken)
+
+	return getBoard(client, boardName).ID
+}
+
+func getBoardById(client *t.Client, id string) *t.Board {
+	board, err := client.GetBoard(id)
+	if err != nil {
+		log.Fatalf("Failed to get board %s: %v\n", id, err)
+	}
+
+	return board
+}
+
+// Trello answers with a 400 rather than a 404 for IDs that are not in the shape of a board ID
+func isInvalidId(err error) bool {
+	return strings.Contains(err.Error(), "invalid id")
+}
+
+func getBoardByName(client *t.Client, name string) *t.Board {
+	boards, err := client.GetMyBoards(t.Arguments{"filter": "all", "fields": "name,closed,idOrganization,url,shortLink"})
+	if err != nil {
+		log.Fatalf("Failed to list boards: %v\n", err)
+	}
+
+	var exact, caseInsensitive, similar []*t.Board
+	for _, board := range boards {
+		switch {
+		case board.Name == name:
+			exact = append(exact, board)
+		case strings.EqualFold(board.Name, name):
+			caseInsensitive = append(caseInsensitive, board)
+		case strings.Contains(strings.ToLower(board.Name), strings.ToLower(name)):
+			similar = append(similar, board)
+		}
+	}
+
+	matches := exact
+	if len(matches) == 0 {
+		matches = caseInsensitive
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0]
+	case len(matches) > 1:
+		log.Fatalf(
+			"Found %d boards named %s, select one by its ID or URL instead:\n%s",
+			len(matches),
+			name,
+			describeBoards(matches),
+		)
+	case len(similar) > 0:
+		log.Fatalf("Unable to find board named %s. Boards with similar names:\n%s", name, describeBoards(similar))
+	default:
+		log.Fatalf("Unable to find board named %s. Run \"baleen boards\" to list the boards you can access\n", name)
+	}
+
+	return nil
+}
+
+func describeBoards(boards []*t.Board) string {
+	var description strings.Builder
+	for _, board := range boards {
+		closed := ""
+		if board.Closed {
+			closed = " (closed)"
+		}
+
+		fmt.Fprintf(&description, "  %s  %s  %s%s\n", board.ID, board.Name, board.URL, closed)
+	}
+
+	return description.String()
+}
+
+// Board listed by ListBoards along with the IDs of its open cards, which Trello nests in the same response
+type listedBoard struct {
+	t.Board
+	Cards []struct {
+		ID string `json:"id"`
+	} `json:"cards"`
+}
+
+// Prints the boards that the Trello account can access with the organisation they belong to and their number of open
+// cards. The cards are read along with the boards, only their IDs, so that counting them takes no extra requests.
+func ListBoards(envPath string) {
+	env := env.New(envPath)
+	client := t.NewClient(env.TrelloKey, env.TrelloToken)
+
+	var boards []*listedBoard
+	err := client.Get("members/me/boards", t.Arguments{
+		"filter":      "all",
+		"fields":      "name,closed,idOrganization,url,shortLink",
+		"cards":       "open",
+		"card_fields": "id",
+	}, &boards)
+	if err != nil {
+		log.Fatalf("Failed to list boards: %v\n", err)
+	}
+
+	organizations := make(map[string]string)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tORGANISATION\tOPEN CARDS\tURL")
+
+	for _, board := range boards {
+		organization := "-"
+		if id := board.IDOrganization; id != "" {
+			if _, ok := organizations[id]; !ok {
+				organizations[id] = getOrganizationName(client, id)
+			}
+			organization = organizations[id]
+		}
+
+		name := board.Name
+		if board.Closed {
+			name += " (closed)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", board.ID, name, organization, len(board.Cards), board.URL)
+	}
+
+	w.Flush()
+}
+
+// Boards can belong to organisations that the account is not a member of, in which case only the ID is known
+func getOrganizationName(client *t.Client, id string) string {
+	organization, err := client.GetOrganization(id, t.Arguments{"fields": "displayName"})
+	if err != nil {
+		return id
+	}
+
+	return organization.DisplayName
+}
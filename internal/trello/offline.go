@@ -0,0 +1,120 @@
+package trello
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+
+	t "github.com/adlio/trello"
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+// Board as downloaded from Trello's "Print and export" menu, holding every resource of the board in one file
+type boardExport struct {
+	Id           string           `json:"id"`
+	Name         string           `json:"name"`
+	Url          string           `json:"url"`
+	Labels       []*t.Label       `json:"labels"`
+	Lists        []*t.List        `json:"lists"`
+	Cards        []*trelloCard    `json:"cards"`
+	Members      []*t.Member      `json:"members"`
+	Checklists   []*t.Checklist   `json:"checklists"`
+	CustomFields []*t.CustomField `json:"customFields"`
+	Actions      []*t.Action      `json:"actions"`
+}
+
+// Reads the cards from a board exported as JSON from Trello, without needing any Trello credentials. Trello only
+// includes the latest 1000 actions of the board in the export, so the older comments of a large board are missing.
+func ParseBoardExport(exportPath string, options ExportOptions) *types.Save {
+	log.Printf("Reading Trello board export %s\n", exportPath)
+
+	data, err := os.ReadFile(exportPath)
+	if err != nil {
+		log.Fatalf("Failed to read Trello board export %s: %v\n", exportPath, err)
+	}
+
+	var board boardExport
+	if err := json.Unmarshal(data, &board); err != nil {
+		log.Fatalf("Failed to parse Trello board export %s: %v\n", exportPath, err)
+	}
+
+	listFilter, openListCards, closedListCards := options.filters()
+
+	lists := make(map[string]*t.List)
+	var exportedLists []*t.List
+	for _, list := range board.Lists {
+		lists[list.ID] = list
+		if !list.Closed || listFilter != "open" {
+			exportedLists = append(exportedLists, list)
+		}
+	}
+
+	members := toMembers(board.Members)
+	customFields := toCustomFields(board.CustomFields)
+
+	comments := make(map[string][]*types.Comment)
+	for _, action := range board.Actions {
+		if action.Type == "commentCard" && action.Data != nil && action.Data.Card != nil {
+			comments[action.Data.Card.ID] = append(comments[action.Data.Card.ID], toComment(action))
+		}
+	}
+
+	checklists := make(map[string][]*types.Checklist)
+	for _, checklist := range board.Checklists {
+		checklists[checklist.IDCard] = append(checklists[checklist.IDCard], toChecklist(checklist))
+	}
+
+	var cards []*types.Card
+	for _, card := range board.Cards {
+		list, ok := lists[card.IDList]
+		if !ok {
+			log.Printf("Skipping card %s as its list is missing from the export\n", card.Name)
+			continue
+		}
+
+		if list.Closed && listFilter == "open" {
+			continue
+		}
+
+		filter := openListCards
+		if list.Closed {
+			filter = closedListCards
+		}
+		if (filter == "open" && card.Closed) || (filter == "closed" && !card.Closed) || !options.changed(card) {
+			continue
+		}
+
+		typesCard := toCard(card, list, members, customFields)
+
+		if cardComments, ok := comments[card.ID]; ok {
+			sort.SliceStable(cardComments, func(i, j int) bool {
+				return cardComments[i].Date.Before(*cardComments[j].Date)
+			})
+			typesCard.Comments = cardComments
+		}
+
+		for _, attachment := range card.Attachments {
+			typesCard.Attachments = append(typesCard.Attachments, toAttachment(attachment))
+		}
+
+		if cardChecklists, ok := checklists[card.ID]; ok {
+			sort.SliceStable(cardChecklists, func(i, j int) bool {
+				return cardChecklists[i].Position < cardChecklists[j].Position
+			})
+			typesCard.Checklists = cardChecklists
+		}
+
+		cards = append(cards, typesCard)
+	}
+
+	log.Printf("Read %d cards from %s\n", len(cards), board.Name)
+
+	return types.NewSave(
+		&types.Board{Id: board.Id, Name: board.Name, Url: board.Url},
+		toLists(exportedLists),
+		toLabels(board.Labels),
+		toCustomFieldDefinitions(customFields),
+		cards,
+	)
+}
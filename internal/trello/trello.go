@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"log"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	t "github.com/adlio/trello"
 	"github.com/woojiahao/baleen/internal/env"
@@ -15,7 +19,7 @@ func ArchiveAll(boardName, envPath string) {
 
 	env := env.New(envPath)
 	client := t.NewClient(env.TrelloKey, env.TrelloToken)
-	lists := getLists(client, boardName)
+	lists := getLists(getBoard(client, boardName), "open")
 
 	for _, list := range lists {
 		log.Printf("Archiving %s\n", list.Name)
@@ -32,144 +36,606 @@ func ArchiveAll(boardName, envPath string) {
 	}
 }
 
-func ExportTrelloBoard(boardName, envPath string) []*types.Card {
+// Most actions that Trello returns in a single request
+const actionsPerPage = 1000
+
+// Options for exporting a Trello board
+type ExportOptions struct {
+	// Number of cards whose comments, attachments and checklists are fetched at the same time
+	Concurrency int
+	// Also export archived cards and the cards in archived lists
+	IncludeArchived bool
+	// Only export archived cards and the cards in archived lists
+	OnlyArchived bool
+	// Only export the cards with activity after this time. Every card is exported when nil.
+	ChangedSince *time.Time
+}
+
+// Filters for the lists to export and the cards to export from open and archived lists
+func (options ExportOptions) filters() (lists, openListCards, closedListCards string) {
+	switch {
+	case options.OnlyArchived:
+		return "all", "closed", "all"
+	case options.IncludeArchived:
+		return "all", "all", "all"
+	default:
+		return "open", "open", "open"
+	}
+}
+
+// Whether the card has had activity since the cards were last exported. Cards that Trello reports no activity for are
+// always exported.
+func (options ExportOptions) changed(card *trelloCard) bool {
+	if options.ChangedSince == nil || card.DateLastActivity == nil {
+		return true
+	}
+
+	return card.DateLastActivity.After(*options.ChangedSince)
+}
+
+// Exports the board along with its lists and labels
+func ExportTrelloBoard(boardName, envPath string, options ExportOptions) *types.Save {
 	log.Printf("Extracting Trello board %s\n", boardName)
 
 	env := env.New(envPath)
 	client := t.NewClient(env.TrelloKey, env.TrelloToken)
-	lists := getLists(client, boardName)
+	board := getBoard(client, boardName)
+	listFilter, openListCards, closedListCards := options.filters()
+	lists := getLists(board, listFilter)
+	members := getMembers(board)
+	customFields := getCustomFields(board)
 
 	var normalCards, specialCards []*types.Card
+	// Number of comments Trello reports for each special card, to check that every comment was exported
+	commentCounts := make(map[string]int)
 
 	for _, list := range lists {
 		log.Printf("Extracting %s\n", list.Name)
 
-		cards, err := list.GetCards()
+		cardFilter := openListCards
+		if list.Closed {
+			cardFilter = closedListCards
+		}
+
+		cards, err := getCards(client, list, cardFilter)
 		if err != nil {
 			log.Fatalf("Failed to get cards from %s: %v\n", boardName, err)
 		}
 
 		for _, card := range cards {
-			var labels []*types.Label
-			for _, label := range card.Labels {
-				labels = append(labels, &types.Label{
-					Name:  label.Name,
-					Color: label.Color,
-				})
+			if !options.changed(card) {
+				continue
 			}
 
-			isSpecial := card.Badges.Attachments > 0 || card.Badges.Comments > 0
-
-			typesCard := &types.Card{
-				Id:             card.ID,
-				Name:           card.Name,
-				Description:    card.Desc,
-				ParentListName: list.Name,
-				Labels:         labels,
-				LastUpdate:     card.DateLastActivity,
-				IsSpecial:      isSpecial,
-				Comments:       []string{},
-				Attachments:    []*types.Attachment{},
-			}
+			typesCard := toCard(card, list, members, customFields)
 
-			if isSpecial {
+			if typesCard.IsSpecial {
 				specialCards = append(specialCards, typesCard)
+				commentCounts[card.ID] = card.Badges.Comments
 			} else {
 				normalCards = append(normalCards, typesCard)
 			}
 		}
 	}
 
-	specialCards = processSpecialCards(client, specialCards)
+	specialCards = processSpecialCards(client, specialCards, commentCounts, options.Concurrency)
 
 	var typesCards []*types.Card
 	typesCards = append(typesCards, specialCards...)
 	typesCards = append(typesCards, normalCards...)
 
-	return typesCards
+	return types.NewSave(
+		toBoard(board),
+		toLists(lists),
+		getLabels(board),
+		toCustomFieldDefinitions(customFields),
+		typesCards,
+	)
 }
 
-func getLists(client *t.Client, boardName string) []*t.List {
-	boards, err := client.SearchBoards(boardName)
-	if err != nil {
-		log.Fatalf("Failed to find %s: %v\n", boardName, err)
+// Converts a Trello card without its comments, attachments and checklists, which are fetched separately
+func toCard(
+	card *trelloCard,
+	list *t.List,
+	members map[string]*types.Member,
+	customFields map[string]*t.CustomField,
+) *types.Card {
+	var labels []*types.Label
+	for _, label := range card.Labels {
+		labels = append(labels, &types.Label{
+			Name:  label.Name,
+			Color: label.Color,
+		})
 	}
 
-	lists, err := boards[0].GetLists()
+	return &types.Card{
+		Id:             card.ID,
+		Name:           card.Name,
+		ShortLink:      card.ShortLink,
+		Description:    card.Desc,
+		ParentListName: list.Name,
+		Labels:         labels,
+		LastUpdate:     card.DateLastActivity,
+		Start:          card.Start,
+		Due:            card.Due,
+		DueComplete:    card.DueComplete,
+		Archived:       card.Closed || list.Closed,
+		IsSpecial:      card.Badges.Attachments > 0 || card.Badges.Comments > 0 || len(card.IDCheckLists) > 0,
+		Comments:       []*types.Comment{},
+		Attachments:    []*types.Attachment{},
+		Checklists:     []*types.Checklist{},
+		Cover:          toCover(card),
+		Members:        cardMembers(card, members),
+		CustomFields:   cardCustomFields(card, customFields),
+	}
+}
+
+// Trello card with the fields that the Trello client does not expose
+type trelloCard struct {
+	t.Card
+	Start       *time.Time          `json:"start"`
+	Cover       *trelloCover        `json:"cover"`
+	Attachments []*trelloAttachment `json:"attachments"`
+}
+
+type trelloCover struct {
+	IdAttachment string `json:"idAttachment"`
+	Color        string `json:"color"`
+	// Covers picked from Unsplash are given as scaled copies of the photo
+	Scaled []struct {
+		URL   string `json:"url"`
+		Width int    `json:"width"`
+	} `json:"scaled"`
+}
+
+// The Trello client reads the size of an attachment from the wrong field so it is read here instead
+type trelloAttachment struct {
+	t.Attachment
+	Bytes int `json:"bytes"`
+}
+
+// Comment action along with the reactions to it, which the Trello client does not support
+type trelloAction struct {
+	t.Action
+	Reactions []*trelloReaction `json:"reactions"`
+}
+
+// Reaction of one member to a comment
+type trelloReaction struct {
+	Emoji struct {
+		Native string `json:"native"`
+	} `json:"emoji"`
+}
+
+func toCover(card *trelloCard) *types.Cover {
+	cover := &types.Cover{AttachmentId: card.IDAttachmentCover}
+
+	if card.Cover != nil {
+		if card.Cover.IdAttachment != "" {
+			cover.AttachmentId = card.Cover.IdAttachment
+		}
+		cover.Color = card.Cover.Color
+
+		if cover.AttachmentId == "" {
+			width := 0
+			for _, scaled := range card.Cover.Scaled {
+				if scaled.Width > width {
+					cover.Url, width = scaled.URL, scaled.Width
+				}
+			}
+		}
+	}
+
+	if cover.AttachmentId == "" && cover.Color == "" && cover.Url == "" {
+		return nil
+	}
+
+	return cover
+}
+
+// Gets the cards in the list that match the filter: "open", "closed" or "all"
+func getCards(client *t.Client, list *t.List, filter string) ([]*trelloCard, error) {
+	var cards []*trelloCard
+	err := client.Get(path.Join("lists", list.ID, "cards", filter), t.Arguments{"customFieldItems": "true"}, &cards)
+
+	return cards, err
+}
+
+// Gets the lists on the board that match the filter: "open", "closed" or "all"
+func getLists(board *t.Board, filter string) []*t.List {
+	lists, err := board.GetLists(t.Arguments{"filter": filter})
 	if err != nil {
-		log.Fatalf("Failed to get lists of board %s: %v\n", boardName, err)
+		log.Fatalf("Failed to get lists of board %s: %v\n", board.Name, err)
 	}
 
 	return lists
 }
 
-func processSpecialCards(client *t.Client, specialCards []*types.Card) []*types.Card {
-	log.Println("Processing special cards...")
+func toBoard(board *t.Board) *types.Board {
+	return &types.Board{
+		Id:   board.ID,
+		Name: board.Name,
+		Url:  board.URL,
+	}
+}
+
+func toLists(lists []*t.List) []*types.List {
+	typesLists := []*types.List{}
+	for _, list := range lists {
+		typesLists = append(typesLists, &types.List{
+			Id:       list.ID,
+			Name:     list.Name,
+			Position: float64(list.Pos),
+			Archived: list.Closed,
+		})
+	}
+
+	sort.SliceStable(typesLists, func(i, j int) bool {
+		return typesLists[i].Position < typesLists[j].Position
+	})
+
+	return typesLists
+}
+
+func getLabels(board *t.Board) []*types.Label {
+	labels, err := board.GetLabels()
+	if err != nil {
+		log.Fatalf("Failed to get labels of board %s: %v\n", board.Name, err)
+	}
+
+	return toLabels(labels)
+}
+
+func toLabels(labels []*t.Label) []*types.Label {
+	typesLabels := []*types.Label{}
+	for _, label := range labels {
+		typesLabels = append(typesLabels, &types.Label{
+			Name:  label.Name,
+			Color: label.Color,
+		})
+	}
+
+	return typesLabels
+}
+
+// Members of the board keyed by their ID. Trello only shares the email of a member with the member themselves.
+func getMembers(board *t.Board) map[string]*types.Member {
+	boardMembers, err := board.GetMembers(t.Arguments{"fields": "fullName,username,email"})
+	if err != nil {
+		log.Fatalf("Failed to get members of board %s: %v\n", board.Name, err)
+	}
+
+	return toMembers(boardMembers)
+}
+
+func toMembers(boardMembers []*t.Member) map[string]*types.Member {
+	members := make(map[string]*types.Member)
+	for _, member := range boardMembers {
+		members[member.ID] = &types.Member{
+			Id:       member.ID,
+			Username: member.Username,
+			FullName: member.FullName,
+			Email:    member.Email,
+		}
+	}
+
+	return members
+}
+
+// Custom fields of the board keyed by their ID
+func getCustomFields(board *t.Board) map[string]*t.CustomField {
+	boardFields, err := board.GetCustomFields()
+	if err != nil {
+		log.Fatalf("Failed to get custom fields of board %s: %v\n", board.Name, err)
+	}
+
+	return toCustomFields(boardFields)
+}
+
+func toCustomFields(boardFields []*t.CustomField) map[string]*t.CustomField {
+	customFields := make(map[string]*t.CustomField)
+	for _, field := range boardFields {
+		customFields[field.ID] = field
+	}
 
-	chunks := types.ChunkEvery(specialCards, 10)
+	return customFields
+}
+
+// Custom fields of the board in the order they appear on the board
+func toCustomFieldDefinitions(customFields map[string]*t.CustomField) []*types.CustomFieldDefinition {
+	var fields []*t.CustomField
+	for _, field := range customFields {
+		fields = append(fields, field)
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Pos < fields[j].Pos
+	})
+
+	definitions := []*types.CustomFieldDefinition{}
+	for _, field := range fields {
+		options := append([]*t.CustomFieldOption{}, field.Options...)
+		sort.SliceStable(options, func(i, j int) bool {
+			return options[i].Pos < options[j].Pos
+		})
+
+		definition := &types.CustomFieldDefinition{Name: field.Name, Type: field.Type}
+		for _, option := range options {
+			definition.Options = append(definition.Options, &types.Label{
+				Name:  option.Value.Text,
+				Color: optionColor(option),
+			})
+		}
 
-	var updatedSpecials []*types.Card
-	c := make(chan []*types.Card)
+		definitions = append(definitions, definition)
+	}
 
-	for i, chunk := range chunks {
-		go parallelProcessSpecial(client, chunk[:len(chunk)/2], c)
-		go parallelProcessSpecial(client, chunk[len(chunk)/2:], c)
+	return definitions
+}
+
+// Color of a list field's option, which Trello gives as "none" for options without a color
+func optionColor(option *t.CustomFieldOption) string {
+	if option.Color == "none" {
+		return ""
+	}
 
-		specialLeft, specialRight := <-c, <-c
-		updatedSpecials = append(updatedSpecials, specialLeft...)
-		updatedSpecials = append(updatedSpecials, specialRight...)
+	return option.Color
+}
 
-		if (i+1)%10 == 0 {
-			log.Printf("Processed %d/%d\n", i+1, len(chunks))
+// Values of the custom fields set on the card, in the order the fields appear on the board
+func cardCustomFields(card *trelloCard, customFields map[string]*t.CustomField) []*types.CustomField {
+	var items []*t.CustomFieldItem
+	for _, item := range card.CustomFieldItems {
+		if _, ok := customFields[item.IDCustomField]; ok {
+			items = append(items, item)
 		}
 	}
 
-	log.Printf("Processed all special cards!")
+	sort.SliceStable(items, func(i, j int) bool {
+		return customFields[items[i].IDCustomField].Pos < customFields[items[j].IDCustomField].Pos
+	})
+
+	values := []*types.CustomField{}
+	for _, item := range items {
+		field := customFields[item.IDCustomField]
+		value := &types.CustomField{Name: field.Name, Type: field.Type}
+
+		switch v := item.Value.Get().(type) {
+		case string:
+			value.Text = v
+		case int:
+			value.Number = float64(v)
+		case int64:
+			value.Number = float64(v)
+		case float64:
+			value.Number = v
+		case time.Time:
+			value.Date = &v
+		case bool:
+			value.Checked = v
+		}
+
+		for _, option := range field.Options {
+			if item.IDValue != "" && option.ID == item.IDValue {
+				value.Text, value.Color = option.Value.Text, optionColor(option)
+			}
+		}
+
+		values = append(values, value)
+	}
 
-	return updatedSpecials
+	return values
 }
 
-func parallelProcessSpecial(client *t.Client, specialCards []*types.Card, c chan []*types.Card) {
-	var cards []*types.Card
-	for _, card := range specialCards {
-		comments, attachments := getSpecial(client, card.Id)
+// Members of the card. Members that have since left the board are only known by their ID.
+func cardMembers(card *trelloCard, members map[string]*types.Member) []*types.Member {
+	cardMembers := []*types.Member{}
+	for _, id := range card.IDMembers {
+		member, ok := members[id]
+		if !ok {
+			member = &types.Member{Id: id}
+		}
+
+		cardMembers = append(cardMembers, member)
+	}
+
+	return cardMembers
+}
+
+func processSpecialCards(
+	client *t.Client,
+	specialCards []*types.Card,
+	commentCounts map[string]int,
+	concurrency int,
+) []*types.Card {
+	log.Println("Processing special cards...")
+
+	failed := 0
+	var mismatched []string
+
+	types.ProcessCards(specialCards, concurrency, func(card *types.Card) error {
+		comments, attachments, checklists, err := getSpecial(client, card.Id)
+		if err != nil {
+			return err
+		}
+
 		card.Comments = comments
 		card.Attachments = attachments
-		cards = append(cards, card)
+		card.Checklists = checklists
+		return nil
+	}, func(result types.CardResult) {
+		if result.Err != nil {
+			log.Printf("Failed to get the comments, attachments and checklists of %s: %v\n", result.Card.Name, result.Err)
+			failed++
+		} else if expected := commentCounts[result.Card.Id]; len(result.Card.Comments) != expected {
+			log.Printf(
+				"Card %s has %d comments on Trello but %d were exported\n",
+				result.Card.Name,
+				expected,
+				len(result.Card.Comments),
+			)
+			mismatched = append(mismatched, result.Card.Name)
+		}
+
+		if (result.Index+1)%50 == 0 {
+			log.Printf("Processed %d/%d\n", result.Index+1, len(specialCards))
+		}
+	})
+
+	if failed > 0 {
+		log.Printf("Processed all special cards, %d could not be fully exported\n", failed)
+	} else {
+		log.Printf("Processed all special cards!")
+	}
+
+	if len(mismatched) > 0 {
+		log.Printf("The comments of %d cards do not match Trello: %s\n", len(mismatched), strings.Join(mismatched, ", "))
 	}
 
-	c <- cards
+	return specialCards
 }
 
-func getSpecial(client *t.Client, cardId string) ([]string, []*types.Attachment) {
-	comments := []string{}
+func getSpecial(client *t.Client, cardId string) ([]*types.Comment, []*types.Attachment, []*types.Checklist, error) {
 	attachments := []*types.Attachment{}
+	checklists := []*types.Checklist{}
 
-	var specialCard *t.Card
-	client.Get(
+	comments, err := getComments(client, cardId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var specialCard *trelloCard
+	err = client.Get(
 		fmt.Sprintf("cards/%s", cardId),
 		map[string]string{
-			"actions":           "commentCard",
 			"attachments":       "true",
 			"fields":            "name",
 			"attachment_fields": "all",
+			"checklists":        "all",
 		},
 		&specialCard,
 	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, attachment := range specialCard.Attachments {
+		attachments = append(attachments, toAttachment(attachment))
+	}
+
+	for _, checklist := range specialCard.Checklists {
+		checklists = append(checklists, toChecklist(checklist))
+	}
+
+	sort.SliceStable(checklists, func(i, j int) bool {
+		return checklists[i].Position < checklists[j].Position
+	})
+
+	return comments, attachments, checklists, nil
+}
+
+// Trello caps the comments nested in a card so they are read from the card's actions instead, a page at a time from the
+// newest to the oldest until there are none left. The reactions to the comments are read along with them.
+func getComments(client *t.Client, cardId string) ([]*types.Comment, error) {
+	comments := []*types.Comment{}
+	args := t.Arguments{
+		"filter":    "commentCard",
+		"limit":     strconv.Itoa(actionsPerPage),
+		"reactions": "true",
+	}
+
+	for {
+		var actions []*trelloAction
+		if err := client.Get(path.Join("cards", cardId, "actions"), args, &actions); err != nil {
+			return nil, err
+		}
+
+		for _, action := range actions {
+			comment := toComment(&action.Action)
+			comment.Reactions = toReactions(action.Reactions)
+			comments = append(comments, comment)
+		}
 
-	for _, action := range specialCard.Actions {
-		if action.Type == "commentCard" {
-			comments = append(comments, action.Data.Text)
+		if len(actions) < actionsPerPage {
+			break
 		}
+		args["before"] = actions[len(actions)-1].ID
 	}
 
-	for _, attachment := range specialCard.Attachments {
-		attachments = append(attachments, &types.Attachment{
-			IsUpload: attachment.IsUpload,
-			Name:     attachment.Name,
-			Url:      attachment.URL,
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Date.Before(*comments[j].Date)
+	})
+
+	return comments, nil
+}
+
+func toComment(action *t.Action) *types.Comment {
+	date := action.Date
+	comment := &types.Comment{
+		Date:   &date,
+		Edited: !action.Data.DateLastEdited.IsZero(),
+		Text:   action.Data.Text,
+	}
+
+	if action.MemberCreator != nil {
+		comment.Author = action.MemberCreator.FullName
+		comment.Username = action.MemberCreator.Username
+	}
+
+	return comment
+}
+
+// Counts the reactions to a comment by their emoji, in the order each emoji was first reacted with
+func toReactions(memberReactions []*trelloReaction) []*types.Reaction {
+	var reactions []*types.Reaction
+	counts := make(map[string]*types.Reaction)
+
+	for _, reaction := range memberReactions {
+		emoji := reaction.Emoji.Native
+		if emoji == "" {
+			continue
+		}
+
+		if counted, ok := counts[emoji]; ok {
+			counted.Count++
+			continue
+		}
+
+		counts[emoji] = &types.Reaction{Emoji: emoji, Count: 1}
+		reactions = append(reactions, counts[emoji])
+	}
+
+	return reactions
+}
+
+func toAttachment(attachment *trelloAttachment) *types.Attachment {
+	return &types.Attachment{
+		Id:       attachment.ID,
+		IsUpload: attachment.IsUpload,
+		Name:     attachment.Name,
+		Url:      attachment.URL,
+		MimeType: attachment.MimeType,
+		Bytes:    attachment.Bytes,
+	}
+}
+
+// Converts a Trello checklist into a checklist with its items sorted by their position on the card
+func toChecklist(checklist *t.Checklist) *types.Checklist {
+	items := []*types.ChecklistItem{}
+	for _, item := range checklist.CheckItems {
+		items = append(items, &types.ChecklistItem{
+			Name:     item.Name,
+			Checked:  item.State == "complete",
+			Position: item.Pos,
 		})
 	}
 
-	return comments, attachments
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Position < items[j].Position
+	})
+
+	return &types.Checklist{
+		Name:     checklist.Name,
+		Position: checklist.Pos,
+		Items:    items,
+	}
 }
@@ -0,0 +1,45 @@
+package trello
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/woojiahao/baleen/internal/types"
+)
+
+func TestCommentReactionsAreCountedByEmoji(t *testing.T) {
+	data := `{
+		"id": "action", "type": "commentCard", "date": "2022-01-02T03:04:05.000Z",
+		"data": {"text": "nice"},
+		"memberCreator": {"fullName": "Alex Tan", "username": "alextan"},
+		"reactions": [
+			{"idMember": "1", "emoji": {"native": "👍"}},
+			{"idMember": "2", "emoji": {"native": "🎉"}},
+			{"idMember": "3", "emoji": {"native": "👍"}}
+		]
+	}`
+
+	var action trelloAction
+	if err := json.Unmarshal([]byte(data), &action); err != nil {
+		t.Fatal(err)
+	}
+
+	comment := toComment(&action.Action)
+	comment.Reactions = toReactions(action.Reactions)
+
+	if comment.Text != "nice" || comment.Username != "alextan" {
+		t.Errorf("comment = %+v, want the text and author of the action", comment)
+	}
+
+	want := []*types.Reaction{{Emoji: "👍", Count: 2}, {Emoji: "🎉", Count: 1}}
+	if !reflect.DeepEqual(comment.Reactions, want) {
+		t.Errorf("reactions = %v, want %v", comment.Reactions, want)
+	}
+}
+
+func TestCommentsWithoutReactions(t *testing.T) {
+	if reactions := toReactions(nil); reactions != nil {
+		t.Errorf("reactions = %v, want none", reactions)
+	}
+}
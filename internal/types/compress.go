@@ -0,0 +1,166 @@
+package types
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Extensions of the compressed save files. The compression of a save is chosen by the extension of its path.
+const (
+	gzipExtension = ".gz"
+	zstdExtension = ".zst"
+)
+
+// Extension of the save file without the compression extension, such as .json or .ndjson
+func saveExtension(savePath string) string {
+	return filepath.Ext(strings.TrimSuffix(strings.TrimSuffix(savePath, gzipExtension), zstdExtension))
+}
+
+// Creates the file at path, compressing everything written to it when the path ends with a compression extension. The
+// file is written to a temporary file next to it that only replaces the file at path once it is closed, so a write
+// that is interrupted never leaves a partial file behind. Missing parent directories are created.
+func createCompressed(path string) (*atomicFile, error) {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		log.Printf("Creating folder %s\n", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	f := &atomicFile{Writer: file, file: file, path: path}
+
+	// Temporary files are only readable by their owner, unlike the file that they replace
+	if err := file.Chmod(0644); err != nil {
+		f.Abort()
+		return nil, err
+	}
+
+	switch filepath.Ext(path) {
+	case gzipExtension:
+		f.compressor = gzip.NewWriter(file)
+	case zstdExtension:
+		encoder, err := zstd.NewWriter(file)
+		if err != nil {
+			f.Abort()
+			return nil, err
+		}
+		f.compressor = encoder
+	}
+	if f.compressor != nil {
+		f.Writer = f.compressor
+	}
+
+	return f, nil
+}
+
+// Temporary file, possibly compressed, that replaces the file at path once it is closed
+type atomicFile struct {
+	io.Writer
+	// Compresses what is written to the file, nil when the file is not compressed
+	compressor io.WriteCloser
+	file       *os.File
+	path       string
+}
+
+// Flushes everything written to the file to disk and moves it to its path
+func (f *atomicFile) Close() error {
+	if f.compressor != nil {
+		if err := f.compressor.Close(); err != nil {
+			f.Abort()
+			return err
+		}
+	}
+
+	if err := f.file.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+
+	if err := f.file.Close(); err != nil {
+		os.Remove(f.file.Name())
+		return err
+	}
+
+	if err := os.Rename(f.file.Name(), f.path); err != nil {
+		os.Remove(f.file.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Discards the file, leaving the file at path as it was
+func (f *atomicFile) Abort() {
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
+
+// Writes data to the file at path like createCompressed, replacing the file only once all of it is written
+func WriteFileAtomic(path string, data []byte) error {
+	file, err := createCompressed(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Abort()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Opens the file at path, decompressing it when the path ends with a compression extension
+func openCompressed(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch filepath.Ext(path) {
+	case gzipExtension:
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &compressedReader{reader, reader.Close, file}, nil
+	case zstdExtension:
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &compressedReader{decoder, func() error { decoder.Close(); return nil }, file}, nil
+	}
+
+	return file, nil
+}
+
+// Decompressor reading from a file, which closes both when closed
+type compressedReader struct {
+	io.Reader
+	close func() error
+	file  *os.File
+}
+
+func (r *compressedReader) Close() error {
+	err := r.close()
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+
+	return err
+}
@@ -0,0 +1,249 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Changes between two saves of a board, matching cards by their IDs
+type SaveDiff struct {
+	// Cards only in the newer save, in the order of the newer save
+	Added []*DiffCard `json:"added"`
+	// Cards only in the older save, in the order of the older save
+	Removed []*DiffCard `json:"removed"`
+	// Cards in both saves that changed, in the order of the newer save
+	Changed []*CardChange `json:"changed"`
+}
+
+type DiffCard struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	List string `json:"list"`
+}
+
+// Changes to a card that is in both saves. Only the fields of the changes that were made are set.
+type CardChange struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Name of the card in the older save when it was renamed
+	OldName string `json:"oldName,omitempty"`
+	// Lists that the card was moved between
+	FromList           string   `json:"fromList,omitempty"`
+	ToList             string   `json:"toList,omitempty"`
+	AddedLabels        []string `json:"addedLabels,omitempty"`
+	RemovedLabels      []string `json:"removedLabels,omitempty"`
+	DescriptionChanged bool     `json:"descriptionChanged,omitempty"`
+	AddedComments      int      `json:"addedComments,omitempty"`
+	RemovedComments    int      `json:"removedComments,omitempty"`
+	AddedAttachments   []string `json:"addedAttachments,omitempty"`
+	RemovedAttachments []string `json:"removedAttachments,omitempty"`
+}
+
+// Compares two saves of a board. The cards of the older save are held in memory while the newer save is read a card at
+// a time.
+func DiffSaves(older, newer CardStream) (*SaveDiff, error) {
+	// Empty rather than null in JSON, for scripts reading the diff
+	diff := &SaveDiff{Added: []*DiffCard{}, Removed: []*DiffCard{}, Changed: []*CardChange{}}
+
+	var olderIds []string
+	olderCards := make(map[string]*Card)
+	err := older.Each(func(card *Card) error {
+		olderIds = append(olderIds, card.Id)
+		olderCards[card.Id] = card
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	err = newer.Each(func(card *Card) error {
+		seen[card.Id] = true
+
+		old, ok := olderCards[card.Id]
+		if !ok {
+			diff.Added = append(diff.Added, diffCard(card))
+		} else if change := diffCards(old, card); change != nil {
+			diff.Changed = append(diff.Changed, change)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range olderIds {
+		if !seen[id] {
+			diff.Removed = append(diff.Removed, diffCard(olderCards[id]))
+		}
+	}
+
+	return diff, nil
+}
+
+func diffCard(card *Card) *DiffCard {
+	return &DiffCard{card.Id, card.Name, card.ParentListName}
+}
+
+// Changes from the older version of a card to the newer one. Returns nil when nothing changed.
+func diffCards(old, new *Card) *CardChange {
+	change := &CardChange{Id: new.Id, Name: new.Name}
+	changed := false
+
+	if old.Name != new.Name {
+		change.OldName = old.Name
+		changed = true
+	}
+
+	if old.ParentListName != new.ParentListName {
+		change.FromList, change.ToList = old.ParentListName, new.ParentListName
+		changed = true
+	}
+
+	change.AddedLabels, change.RemovedLabels = diffKeys(labelNames(old.Labels), labelNames(new.Labels))
+	change.AddedAttachments, change.RemovedAttachments = diffKeys(
+		attachmentNames(old.Attachments),
+		attachmentNames(new.Attachments),
+	)
+	added, removed := diffKeys(commentKeys(old.Comments), commentKeys(new.Comments))
+	change.AddedComments, change.RemovedComments = len(added), len(removed)
+
+	if len(change.AddedLabels)+len(change.RemovedLabels) > 0 ||
+		len(change.AddedAttachments)+len(change.RemovedAttachments) > 0 ||
+		change.AddedComments+change.RemovedComments > 0 {
+		changed = true
+	}
+
+	if old.Description != new.Description {
+		change.DescriptionChanged = true
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return change
+}
+
+// Keys only in new and keys only in old, counting keys that appear more than once separately
+func diffKeys(old, new []string) (added, removed []string) {
+	counts := make(map[string]int)
+	for _, key := range old {
+		counts[key]++
+	}
+
+	for _, key := range new {
+		if counts[key] > 0 {
+			counts[key]--
+		} else {
+			added = append(added, key)
+		}
+	}
+
+	for _, key := range old {
+		if counts[key] > 0 {
+			counts[key]--
+			removed = append(removed, key)
+		}
+	}
+
+	return
+}
+
+func labelNames(labels []*Label) []string {
+	var names []string
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+
+	return names
+}
+
+func attachmentNames(attachments []*Attachment) []string {
+	var names []string
+	for _, attachment := range attachments {
+		name := attachment.Name
+		if name == "" {
+			name = attachment.Url
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Comments are told apart by who posted them, when and what they say, so an edited comment is counted as removed and
+// added again
+func commentKeys(comments []*Comment) []string {
+	var keys []string
+	for _, comment := range comments {
+		date := ""
+		if comment.Date != nil {
+			date = comment.Date.String()
+		}
+
+		keys = append(keys, strings.Join([]string{comment.Username, date, comment.Text}, "\x00"))
+	}
+
+	return keys
+}
+
+// Prints the diff as a table with a row for every change
+func (diff *SaveDiff) PrintTable(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tID\tCARD\tDETAILS")
+
+	for _, card := range diff.Added {
+		fmt.Fprintf(w, "added\t%s\t%s\tin %s\n", card.Id, card.Name, card.List)
+	}
+
+	for _, card := range diff.Removed {
+		fmt.Fprintf(w, "removed\t%s\t%s\tfrom %s\n", card.Id, card.Name, card.List)
+	}
+
+	for _, change := range diff.Changed {
+		row := func(kind, details string) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", kind, change.Id, change.Name, details)
+		}
+
+		if change.FromList != "" || change.ToList != "" {
+			row("moved", fmt.Sprintf("%s -> %s", change.FromList, change.ToList))
+		}
+		if change.OldName != "" {
+			row("renamed", fmt.Sprintf("from %s", change.OldName))
+		}
+		if len(change.AddedLabels)+len(change.RemovedLabels) > 0 {
+			row("labels", formatAddedRemoved(change.AddedLabels, change.RemovedLabels))
+		}
+		if change.DescriptionChanged {
+			row("description", "changed")
+		}
+		if change.AddedComments+change.RemovedComments > 0 {
+			row("comments", fmt.Sprintf("+%d -%d", change.AddedComments, change.RemovedComments))
+		}
+		if len(change.AddedAttachments)+len(change.RemovedAttachments) > 0 {
+			row("attachments", formatAddedRemoved(change.AddedAttachments, change.RemovedAttachments))
+		}
+	}
+
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
+
+func formatAddedRemoved(added, removed []string) string {
+	var parts []string
+	for _, name := range added {
+		parts = append(parts, "+"+name)
+	}
+	for _, name := range removed {
+		parts = append(parts, "-"+name)
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffSaves(t *testing.T) {
+	date := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	older := CardSlice{
+		{Id: "1", Name: "Unchanged", ParentListName: "Doing"},
+		{Id: "2", Name: "Removed", ParentListName: "Doing"},
+		{Id: "3", Name: "Moved", ParentListName: "Doing"},
+		{Id: "4", Name: "Old name", ParentListName: "Done"},
+		{
+			Id:             "5",
+			Name:           "Details",
+			ParentListName: "Done",
+			Description:    "before",
+			Labels:         []*Label{{Name: "Bug"}, {Name: "UI"}},
+			Comments:       []*Comment{{Username: "alex", Date: &date, Text: "first"}},
+			Attachments:    []*Attachment{{Name: "a.png"}},
+		},
+	}
+	newer := CardSlice{
+		{Id: "6", Name: "Added", ParentListName: "Ideas"},
+		{
+			Id:             "5",
+			Name:           "Details",
+			ParentListName: "Done",
+			Description:    "after",
+			Labels:         []*Label{{Name: "UI"}, {Name: "Docs"}},
+			Comments:       []*Comment{{Username: "alex", Date: &date, Text: "first"}, {Username: "sam", Text: "second"}},
+			Attachments:    []*Attachment{{Url: "https://example.com"}},
+		},
+		{Id: "4", Name: "New name", ParentListName: "Done"},
+		{Id: "3", Name: "Moved", ParentListName: "Done"},
+		{Id: "1", Name: "Unchanged", ParentListName: "Doing"},
+	}
+
+	diff, err := DiffSaves(older, newer)
+	if err != nil {
+		t.Fatalf("DiffSaves: %v", err)
+	}
+
+	if want := []*DiffCard{{"6", "Added", "Ideas"}}; !reflect.DeepEqual(diff.Added, want) {
+		t.Errorf("added = %v, want %v", diff.Added, want)
+	}
+	if want := []*DiffCard{{"2", "Removed", "Doing"}}; !reflect.DeepEqual(diff.Removed, want) {
+		t.Errorf("removed = %v, want %v", diff.Removed, want)
+	}
+
+	want := []*CardChange{
+		{
+			Id:                 "5",
+			Name:               "Details",
+			AddedLabels:        []string{"Docs"},
+			RemovedLabels:      []string{"Bug"},
+			DescriptionChanged: true,
+			AddedComments:      1,
+			AddedAttachments:   []string{"https://example.com"},
+			RemovedAttachments: []string{"a.png"},
+		},
+		{Id: "4", Name: "New name", OldName: "Old name"},
+		{Id: "3", Name: "Moved", FromList: "Doing", ToList: "Done"},
+	}
+	if len(diff.Changed) != len(want) {
+		t.Fatalf("got %d changed cards, want %d", len(diff.Changed), len(want))
+	}
+	for i, change := range diff.Changed {
+		if !reflect.DeepEqual(change, want[i]) {
+			t.Errorf("change %d = %+v, want %+v", i, change, want[i])
+		}
+	}
+}
+
+func TestDiffSavesWithoutChanges(t *testing.T) {
+	cards := CardSlice{{Id: "1", Name: "One", ParentListName: "Doing"}}
+
+	diff, err := DiffSaves(cards, cards)
+	if err != nil {
+		t.Fatalf("DiffSaves: %v", err)
+	}
+
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 {
+		t.Errorf("diff = %+v, want no changes", diff)
+	}
+}
+
+func TestDiffKeysCountsRepeatedKeys(t *testing.T) {
+	added, removed := diffKeys([]string{"a", "a", "b"}, []string{"a", "c", "c"})
+
+	if !reflect.DeepEqual(added, []string{"c", "c"}) || !reflect.DeepEqual(removed, []string{"a", "b"}) {
+		t.Errorf("diffKeys = %v, %v, want [c c], [a b]", added, removed)
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	diff := &SaveDiff{
+		Added:   []*DiffCard{{"6", "Added", "Ideas"}},
+		Removed: []*DiffCard{{"2", "Removed", "Doing"}},
+		Changed: []*CardChange{{Id: "3", Name: "Moved", FromList: "Doing", ToList: "Done", OldName: "Was"}},
+	}
+
+	var out bytes.Buffer
+	diff.PrintTable(&out)
+
+	for _, row := range []string{"added    6   Added", "removed  2   Removed", "moved    3   Moved", "renamed  3   Moved"} {
+		if !strings.Contains(out.String(), row) {
+			t.Errorf("table is missing %q:\n%s", row, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "1 added, 1 removed, 1 changed") {
+		t.Errorf("table is missing the totals:\n%s", out.String())
+	}
+}
@@ -0,0 +1,80 @@
+package types
+
+import "sync"
+
+// Outcome of processing a single card in a worker pool
+type CardResult struct {
+	// Position of the card in the cards given to the pool
+	Index int
+	Card  *Card
+	Err   error
+}
+
+// Processes every card with a fixed number of workers fed from a bounded queue, so a slow card only holds up its own
+// worker. Results are passed to report one at a time in the same order as the cards, each as soon as it and every card
+// before it have finished. Returns once every card has been processed and reported.
+func ProcessCards(cards []*Card, concurrency int, process func(*Card) error, report func(CardResult)) {
+	// Cards in memory cannot fail to be read
+	_ = ProcessCardStream(CardSlice(cards), concurrency, process, report)
+}
+
+// Processes the cards like ProcessCards, reading them from the stream as the workers free up rather than all at once.
+// Returns the error that stopped the stream from being read, after the cards read before it have been processed and
+// reported.
+func ProcessCardStream(cards CardStream, concurrency int, process func(*Card) error, report func(CardResult)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	type job struct {
+		index int
+		card  *Card
+	}
+
+	jobs := make(chan job, concurrency)
+	results := make(chan CardResult, concurrency)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- CardResult{Index: j.index, Card: j.card, Err: process(j.card)}
+			}
+		}()
+	}
+
+	var readErr error
+	go func() {
+		i := 0
+		readErr = cards.Each(func(card *Card) error {
+			jobs <- job{i, card}
+			i++
+			return nil
+		})
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Hold on to results that finish early until every card before them has been reported
+	pending := make(map[int]CardResult)
+	next := 0
+	for result := range results {
+		pending[result.Index] = result
+
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+
+			delete(pending, next)
+			report(r)
+			next++
+		}
+	}
+
+	return readErr
+}
@@ -0,0 +1,226 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Version of the save format written by SaveCards. Saves written before the format was versioned are a bare array of
+// cards and are treated as version 1.
+const SaveVersion = 2
+
+// Everything exported from a Trello board
+type Save struct {
+	Version    int        `json:"version"`
+	ExportedAt *time.Time `json:"exportedAt"`
+	Board      *Board     `json:"board"`
+	// Lists of the board in the order they appear on the board
+	Lists []*List `json:"lists"`
+	// Labels defined on the board, including the ones that no card uses
+	Labels []*Label `json:"labels"`
+	// Custom fields defined on the board in the order they appear on the board. Saves written before the custom fields
+	// were kept have none, in which case they are only known from the cards.
+	CustomFields []*CustomFieldDefinition `json:"customFields,omitempty"`
+	// Left out of the first line of a streamed save, which holds one card on every line after it
+	Cards []*Card `json:"cards,omitempty"`
+}
+
+// Board that a save was exported from. Saves of cards that failed to import have no board.
+type Board struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+type List struct {
+	Id       string  `json:"id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"`
+	Archived bool    `json:"archived"`
+}
+
+// Creates a save of the cards at the current version
+func NewSave(
+	board *Board,
+	lists []*List,
+	labels []*Label,
+	customFields []*CustomFieldDefinition,
+	cards []*Card,
+) *Save {
+	now := time.Now().UTC()
+
+	return &Save{
+		Version:      SaveVersion,
+		ExportedAt:   &now,
+		Board:        board,
+		Lists:        lists,
+		Labels:       labels,
+		CustomFields: customFields,
+		Cards:        cards,
+	}
+}
+
+// Upgrades a save from the version before it to the next version
+var saveMigrations = map[int]func(save *Save){
+	// Version 1 only held the cards, so the lists, labels and custom fields are taken from them
+	1: func(save *Save) {
+		seenLists, seenLabels := make(map[string]bool), make(map[string]bool)
+		customFields := make(map[string]*CustomFieldDefinition)
+
+		for _, card := range save.Cards {
+			if !seenLists[card.ParentListName] {
+				seenLists[card.ParentListName] = true
+				save.Lists = append(save.Lists, &List{Name: card.ParentListName, Position: float64(len(save.Lists))})
+			}
+
+			for _, label := range card.Labels {
+				if !seenLabels[label.Name] {
+					seenLabels[label.Name] = true
+					save.Labels = append(save.Labels, &Label{Name: label.Name, Color: label.Color})
+				}
+			}
+
+			for _, value := range card.CustomFields {
+				field, ok := customFields[value.Name]
+				if !ok {
+					field = &CustomFieldDefinition{Name: value.Name, Type: value.Type}
+					customFields[value.Name] = field
+					save.CustomFields = append(save.CustomFields, field)
+				}
+
+				if value.Type == "list" && value.Text != "" && !containsLabel(value.Text, field.Options) {
+					field.Options = append(field.Options, &Label{Name: value.Text, Color: value.Color})
+				}
+			}
+		}
+	},
+}
+
+func containsLabel(name string, labels []*Label) bool {
+	for _, label := range labels {
+		if label.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads the whole save at savePath into memory, upgrading it to the current version if it was written by an older
+// version of baleen. Use OpenCards to read the cards of a large save one at a time instead.
+func ReadSave(savePath string) (*Save, error) {
+	if IsStreamed(savePath) {
+		return readStreamedSave(savePath)
+	}
+
+	file, err := openCompressed(savePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSave(data)
+}
+
+func readStreamedSave(savePath string) (*Save, error) {
+	r, err := OpenSave(savePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	save := r.Header
+	for {
+		card, err := r.Next()
+		if err == io.EOF {
+			return save, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		save.Cards = append(save.Cards, card)
+	}
+}
+
+func ParseSave(data []byte) (*Save, error) {
+	var save Save
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &save.Cards); err != nil {
+			return nil, fmt.Errorf("invalid save: %v", err)
+		}
+		save.Version = 1
+	} else if err := json.Unmarshal(data, &save); err != nil {
+		return nil, fmt.Errorf("invalid save: %v", err)
+	}
+
+	if err := checkVersion(save.Version); err != nil {
+		return nil, err
+	}
+
+	for save.Version < SaveVersion {
+		saveMigrations[save.Version](&save)
+		save.Version++
+	}
+
+	if err := save.validate(); err != nil {
+		return nil, fmt.Errorf("invalid save: %v", err)
+	}
+
+	return &save, nil
+}
+
+// Checks that a save of the version can be read by this version of baleen
+func checkVersion(version int) error {
+	if version < 1 {
+		return fmt.Errorf("invalid save: missing version")
+	}
+	if version > SaveVersion {
+		return fmt.Errorf(
+			"save is version %d but this version of baleen only reads up to version %d, update baleen to read it",
+			version,
+			SaveVersion,
+		)
+	}
+
+	return nil
+}
+
+func (save *Save) validate() error {
+	ids := make(map[string]bool)
+
+	for i, card := range save.Cards {
+		if err := validateCard(i, card, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validates the card at index i of a save, adding its ID to the IDs of the cards before it
+func validateCard(i int, card *Card, ids map[string]bool) error {
+	if card == nil {
+		return fmt.Errorf("card %d is empty", i)
+	}
+	if card.Id == "" {
+		return fmt.Errorf("card %d (%s) has no ID", i, card.Name)
+	}
+	if ids[card.Id] {
+		return fmt.Errorf("card %s (%s) appears more than once", card.Id, card.Name)
+	}
+
+	ids[card.Id] = true
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSaveUpgradesBareArray(t *testing.T) {
+	data := `[
+		{"Id": "1", "Name": "One", "ParentListName": "Doing", "Labels": [{"Name": "Bug", "Color": "red"}],
+			"CustomFields": [{"Name": "Priority", "Type": "list", "Text": "High", "Color": "red"}]},
+		{"Id": "2", "Name": "Two", "ParentListName": "Done", "Labels": [{"Name": "Bug", "Color": "red"}],
+			"CustomFields": [{"Name": "Priority", "Type": "list", "Text": "Low"}, {"Name": "Estimate", "Type": "number"}]},
+		{"Id": "3", "Name": "Three", "ParentListName": "Doing", "Comments": ["an old comment"]}
+	]`
+
+	save, err := ParseSave([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseSave: %v", err)
+	}
+
+	if save.Version != SaveVersion {
+		t.Errorf("version = %d, want %d", save.Version, SaveVersion)
+	}
+	if len(save.Cards) != 3 {
+		t.Fatalf("got %d cards, want 3", len(save.Cards))
+	}
+
+	var lists []string
+	for _, list := range save.Lists {
+		lists = append(lists, list.Name)
+	}
+	if !reflect.DeepEqual(lists, []string{"Doing", "Done"}) {
+		t.Errorf("lists = %v, want [Doing Done]", lists)
+	}
+
+	if !reflect.DeepEqual(save.Labels, []*Label{{Name: "Bug", Color: "red"}}) {
+		t.Errorf("labels = %v, want only Bug", save.Labels)
+	}
+
+	want := []*CustomFieldDefinition{
+		{Name: "Priority", Type: "list", Options: []*Label{{Name: "High", Color: "red"}, {Name: "Low"}}},
+		{Name: "Estimate", Type: "number"},
+	}
+	if !reflect.DeepEqual(save.CustomFields, want) {
+		t.Errorf("custom fields = %v, want %v", save.CustomFields, want)
+	}
+
+	if comments := save.Cards[2].Comments; len(comments) != 1 || comments[0].Text != "an old comment" {
+		t.Errorf("comments = %v, want the old comment's text", comments)
+	}
+}
+
+func TestParseSaveRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"newer version", `{"version": 99, "cards": []}`, "only reads up to version"},
+		{"missing version", `{"cards": []}`, "missing version"},
+		{"duplicate IDs", `{"version": 2, "cards": [{"Id": "1"}, {"Id": "1"}]}`, "appears more than once"},
+		{"duplicate IDs in a bare array", `[{"Id": "1"}, {"Id": "1"}]`, "appears more than once"},
+		{"card without an ID", `{"version": 2, "cards": [{"Name": "no ID"}]}`, "has no ID"},
+		{"empty card", `{"version": 2, "cards": [null]}`, "is empty"},
+		{"malformed JSON", `{"version": 2, "cards": [`, "invalid save"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseSave([]byte(test.data))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("ParseSave error = %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package types
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Extension of streamed saves. A streamed save is newline delimited JSON, with the save holding everything but the
+// cards on the first line followed by one card per line, so that it is written and read a card at a time rather than
+// all at once.
+const StreamedExtension = ".ndjson"
+
+// Whether the save at savePath is streamed, going by its extension
+func IsStreamed(savePath string) bool {
+	return saveExtension(savePath) == StreamedExtension
+}
+
+// Writes a streamed save one card at a time
+type SaveWriter struct {
+	file    *atomicFile
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// Creates a streamed save at savePath and writes everything in the save but its cards, which are written with Write
+func CreateSave(savePath string, save *Save) (*SaveWriter, error) {
+	file, err := createCompressed(savePath)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bufio.NewWriter(file)
+	w := &SaveWriter{file, buffer, json.NewEncoder(buffer)}
+
+	header := *save
+	header.Cards = nil
+	if err := w.encoder.Encode(header); err != nil {
+		file.Abort()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *SaveWriter) Write(card *Card) error {
+	return w.encoder.Encode(card)
+}
+
+// Flushes the cards written to the save and closes it. The save is only written to its path once it is closed.
+func (w *SaveWriter) Close() error {
+	if err := w.buffer.Flush(); err != nil {
+		w.file.Abort()
+		return err
+	}
+
+	return w.file.Close()
+}
+
+// Discards the save without writing it to its path
+func (w *SaveWriter) Abort() {
+	w.file.Abort()
+}
+
+// Reads a streamed save one card at a time
+type SaveReader struct {
+	// Everything in the save but its cards
+	Header *Save
+
+	file    io.ReadCloser
+	decoder *json.Decoder
+	// IDs of the cards read so far, to catch cards that appear more than once
+	ids map[string]bool
+}
+
+// Opens the streamed save at savePath and reads everything in the save but its cards, which are read with Next
+func OpenSave(savePath string) (*SaveReader, error) {
+	file, err := openCompressed(savePath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &SaveReader{file: file, decoder: json.NewDecoder(bufio.NewReader(file)), ids: make(map[string]bool)}
+
+	// Saves were first streamed at version 2, so there are no older streamed saves to migrate
+	var header Save
+	if err := r.decoder.Decode(&header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid save: %v", err)
+	}
+	if err := checkVersion(header.Version); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header.Cards = nil
+	r.Header = &header
+
+	return r, nil
+}
+
+// Reads the next card of the save. Returns io.EOF once every card has been read.
+func (r *SaveReader) Next() (*Card, error) {
+	var card *Card
+	if err := r.decoder.Decode(&card); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid save: card %d: %v", len(r.ids), err)
+	}
+
+	if err := validateCard(len(r.ids), card, r.ids); err != nil {
+		return nil, fmt.Errorf("invalid save: %v", err)
+	}
+
+	return card, nil
+}
+
+func (r *SaveReader) Close() error {
+	return r.file.Close()
+}
+
+// Cards that can be read any number of times, one card at a time. Lets the cards of a save be read in several passes
+// without holding all of them in memory.
+type CardStream interface {
+	// Calls each with every card in order, stopping at the first error
+	Each(each func(*Card) error) error
+}
+
+// Cards already held in memory
+type CardSlice []*Card
+
+func (cards CardSlice) Each(each func(*Card) error) error {
+	for _, card := range cards {
+		if err := each(card); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cards of a streamed save, which is read again from the start on every pass
+type savedCards string
+
+func (savePath savedCards) Each(each func(*Card) error) error {
+	r, err := OpenSave(string(savePath))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		card, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := each(card); err != nil {
+			return err
+		}
+	}
+}
+
+// Cards that keep returns true for
+type filteredCards struct {
+	cards CardStream
+	keep  func(*Card) bool
+}
+
+func FilterCards(cards CardStream, keep func(*Card) bool) CardStream {
+	return filteredCards{cards, keep}
+}
+
+func (f filteredCards) Each(each func(*Card) error) error {
+	return f.cards.Each(func(card *Card) error {
+		if !f.keep(card) {
+			return nil
+		}
+
+		return each(card)
+	})
+}
+
+// Opens the save at savePath, returning everything in it but its cards along with a stream of its cards. Only the
+// cards of streamed saves are read as they are needed, other saves are read into memory up front.
+func OpenCards(savePath string) (*Save, CardStream, error) {
+	if IsStreamed(savePath) {
+		r, err := OpenSave(savePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.Close()
+
+		return r.Header, savedCards(savePath), nil
+	}
+
+	save, err := ReadSave(savePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cards := save.Cards
+	header := *save
+	header.Cards = nil
+
+	return &header, CardSlice(cards), nil
+}
+
+// Writes the save to savePath, streaming its cards one at a time when the path is for a streamed save
+func WriteSave(savePath string, save *Save) error {
+	if !IsStreamed(savePath) {
+		data, _ := json.MarshalIndent(save, "", "  ")
+		return WriteFileAtomic(savePath, data)
+	}
+
+	w, err := CreateSave(savePath, save)
+	if err != nil {
+		return err
+	}
+
+	for _, card := range save.Cards {
+		if err := w.Write(card); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,158 @@
+package types
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testSave() *Save {
+	return NewSave(
+		&Board{Id: "b", Name: "Board"},
+		[]*List{{Id: "l", Name: "Doing"}},
+		[]*Label{{Name: "Bug", Color: "red"}},
+		[]*CustomFieldDefinition{{Name: "Estimate", Type: "number"}},
+		[]*Card{
+			{Id: "1", Name: "One", ParentListName: "Doing", Labels: []*Label{{Name: "Bug", Color: "red"}}},
+			{Id: "2", Name: "Two", ParentListName: "Doing", Description: "line\nbreak"},
+			{Id: "3", Name: "Three", ParentListName: "Doing"},
+		},
+	)
+}
+
+func TestStreamedSaveRoundTrip(t *testing.T) {
+	for _, name := range []string{"save.ndjson", "save.ndjson.gz", "save.ndjson.zst", "save.json.gz", "save.json.zst"} {
+		t.Run(name, func(t *testing.T) {
+			savePath := filepath.Join(t.TempDir(), name)
+			save := testSave()
+
+			if err := WriteSave(savePath, save); err != nil {
+				t.Fatalf("WriteSave: %v", err)
+			}
+
+			header, cards, err := OpenCards(savePath)
+			if err != nil {
+				t.Fatalf("OpenCards: %v", err)
+			}
+			if header.Board.Name != "Board" || len(header.Lists) != 1 || len(header.CustomFields) != 1 || header.Cards != nil {
+				t.Errorf("header = %+v, want the save without its cards", header)
+			}
+
+			// Streamed cards are read again on every pass
+			for pass := 0; pass < 2; pass++ {
+				var read []*Card
+				if err := cards.Each(func(card *Card) error {
+					read = append(read, card)
+					return nil
+				}); err != nil {
+					t.Fatalf("Each: %v", err)
+				}
+
+				if !reflect.DeepEqual(read, save.Cards) {
+					t.Errorf("pass %d read %v, want %v", pass, read, save.Cards)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressedSavesAreCompressed(t *testing.T) {
+	savePath := filepath.Join(t.TempDir(), "save.ndjson.gz")
+	if err := WriteSave(savePath, testSave()); err != nil {
+		t.Fatalf("WriteSave: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(savePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Errorf("save does not start with the gzip magic number")
+	}
+}
+
+func TestStreamedSaveRejectsDuplicateIds(t *testing.T) {
+	savePath := filepath.Join(t.TempDir(), "save.ndjson")
+	data := `{"version": 2}` + "\n" + `{"Id": "1"}` + "\n" + `{"Id": "1"}` + "\n"
+	if err := ioutil.WriteFile(savePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, cards, err := OpenCards(savePath)
+	if err != nil {
+		t.Fatalf("OpenCards: %v", err)
+	}
+
+	err = cards.Each(func(*Card) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "appears more than once") {
+		t.Errorf("Each error = %v, want the duplicate card", err)
+	}
+}
+
+func TestStreamedSaveRejectsNewerVersion(t *testing.T) {
+	savePath := filepath.Join(t.TempDir(), "save.ndjson")
+	if err := ioutil.WriteFile(savePath, []byte(`{"version": 99}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := OpenCards(savePath); err == nil || !strings.Contains(err.Error(), "only reads up to version") {
+		t.Errorf("OpenCards error = %v, want the version to be rejected", err)
+	}
+}
+
+func TestProcessCardStreamStopsAtReadError(t *testing.T) {
+	savePath := filepath.Join(t.TempDir(), "save.ndjson")
+	data := `{"version": 2}` + "\n" + `{"Id": "1"}` + "\n" + `{"Id": "2"}` + "\n" + `{"Id": ` + "\n"
+	if err := ioutil.WriteFile(savePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, cards, err := OpenCards(savePath)
+	if err != nil {
+		t.Fatalf("OpenCards: %v", err)
+	}
+
+	var mu sync.Mutex
+	var processed []string
+	var reported []string
+	err = ProcessCardStream(cards, 4, func(card *Card) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, card.Id)
+		return nil
+	}, func(result CardResult) {
+		reported = append(reported, result.Card.Id)
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "card 2") {
+		t.Errorf("ProcessCardStream error = %v, want the read error at card 2", err)
+	}
+	if !reflect.DeepEqual(reported, []string{"1", "2"}) || len(processed) != 2 {
+		t.Errorf("processed %v and reported %v, want the cards before the error", processed, reported)
+	}
+}
+
+func TestAbortedSaveLeavesNoFile(t *testing.T) {
+	savePath := filepath.Join(t.TempDir(), "save.ndjson.zst")
+
+	w, err := CreateSave(savePath, testSave())
+	if err != nil {
+		t.Fatalf("CreateSave: %v", err)
+	}
+	if err := w.Write(&Card{Id: "1"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	w.Abort()
+
+	if _, err := os.Stat(savePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("save exists after being aborted: %v", err)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Dir(savePath)); len(entries) != 0 {
+		t.Errorf("temporary files were left behind: %v", entries)
+	}
+}
@@ -1,19 +1,31 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // TODO: Change the attachment configuration
 // Special cards are cards with attachments and comments
 type Card struct {
 	Id             string
 	Name           string
+	ShortLink      string
 	Description    string
 	ParentListName string
 	Labels         []*Label
 	LastUpdate     *time.Time
+	Start          *time.Time
+	Due            *time.Time
+	DueComplete    bool
+	Archived       bool
 	IsSpecial      bool
-	Comments       []string
+	Comments       []*Comment
 	Attachments    []*Attachment
+	Checklists     []*Checklist
+	Cover          *Cover
+	Members        []*Member
+	CustomFields   []*CustomField
 }
 
 type Label struct {
@@ -21,8 +33,95 @@ type Label struct {
 	Color string
 }
 
+// Member of the board
+type Member struct {
+	Id       string
+	Username string
+	FullName string
+	// Empty unless the member is the one exporting the board
+	Email string
+}
+
+// Value of a custom field set on a card
+type CustomField struct {
+	Name string
+	// Type of the field as named by Trello: "list", "number", "date", "checkbox" or "text"
+	Type string
+	// Value of a text field or the option picked in a list field
+	Text string
+	// Color of the option picked in a list field
+	Color   string
+	Number  float64
+	Date    *time.Time
+	Checked bool
+}
+
+// Custom field defined on the board, including the options of a list field that no card has picked
+type CustomFieldDefinition struct {
+	Name string
+	// Type of the field as named by Trello: "list", "number", "date", "checkbox" or "text"
+	Type string
+	// Options of a list field in the order they appear on the board
+	Options []*Label
+}
+
+// Comment left on a card
+type Comment struct {
+	// Full name of the member who posted the comment
+	Author   string
+	Username string
+	Date     *time.Time
+	// Whether the comment was changed after it was posted
+	Edited    bool
+	Text      string
+	Reactions []*Reaction
+}
+
+// Emoji that members reacted to a comment with, along with how many of them did
+type Reaction struct {
+	Emoji string
+	Count int
+}
+
+// Saves made before comments kept their author and date hold each comment as just its text
+func (comment *Comment) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		comment.Text = text
+		return nil
+	}
+
+	type plainComment Comment
+	return json.Unmarshal(data, (*plainComment)(comment))
+}
+
 type Attachment struct {
+	Id       string
 	IsUpload bool
 	Name     string
 	Url      string
+	MimeType string
+	// Size of the file in bytes, 0 for links
+	Bytes int
+}
+
+// Cover shown on the front of the card on the board
+type Cover struct {
+	// ID of the attachment used as the cover, empty when the cover is not one of the card's attachments
+	AttachmentId string
+	Color        string
+	// Image used as the cover when it is not an attachment, such as an Unsplash photo
+	Url string
+}
+
+type Checklist struct {
+	Name     string
+	Position float64
+	Items    []*ChecklistItem
+}
+
+type ChecklistItem struct {
+	Name     string
+	Checked  bool
+	Position float64
 }
@@ -1,63 +1,80 @@
 package types
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"log"
-	"math"
-	"os"
-	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func ChunkEvery(cards []*Card, n int) [][]*Card {
-	totalChunks := int(math.Ceil(float64(len(cards)) / float64(n)))
-	excess := len(cards) - ((totalChunks - 1) * n)
-	chunks := make([][]*Card, totalChunks)
+// Formats the time as a sortable ISO 8601 timestamp in UTC that can be used in file names
+func FormatTime(time time.Time) string {
+	timestamp := time.UTC().Format("20060102T150405Z")
+	return timestamp
+}
 
-	for i := 0; i < totalChunks; i++ {
-		if i == totalChunks-1 {
-			// This is the last chunk that might have excess
-			chunks[i] = make([]*Card, excess)
-		} else {
-			chunks[i] = make([]*Card, n)
-		}
-	}
+// Name given to saves when no other name is set. Saves are streamed so that the cards of large boards are never all
+// marshalled at once.
+const DefaultSaveName = "{board}-{date}" + StreamedExtension
 
-	for r := 0; r < totalChunks; r++ {
-		for c := 0; c < n; c++ {
-			if r*n+c < len(cards) {
-				chunks[r][c] = cards[r*n+c]
-			}
-		}
+// Where a save is written to
+type SaveLocation struct {
+	// Path of the save. Overrides Dir and Name when set.
+	Out string
+	// Folder that the save is written to
+	Dir string
+	// Name of the save, where {board} is replaced by the name of the board, {date} by when it was exported and {count}
+	// by the number of cards. Defaults to DefaultSaveName.
+	Name string
+}
+
+// Path that the save is written to
+func (location SaveLocation) Path(save *Save) string {
+	if location.Out != "" {
+		return location.Out
 	}
 
-	return chunks
-}
+	name := location.Name
+	if name == "" {
+		name = DefaultSaveName
+	}
 
-func FormatTime(time time.Time) string {
-	timestamp := time.Format("2006-02-01-15-04-05")
-	return timestamp
-}
+	// Saves of cards that failed to import are not from a board
+	board := "cards"
+	if save.Board != nil && save.Board.Name != "" {
+		board = save.Board.Name
+	}
 
-// TODO: Allow users to customize the entire path
-func SaveCards(cards []*Card, subfolderName string) string {
-	file, _ := json.MarshalIndent(cards, "", "  ")
+	exportedAt := time.Now()
+	if save.ExportedAt != nil {
+		exportedAt = *save.ExportedAt
+	}
 
-	folderPath := path.Join("data", subfolderName)
-	exportPath := path.Join(folderPath, fmt.Sprintf("%s.json", FormatTime(time.Now())))
+	name = strings.NewReplacer(
+		"{board}", fileName(board),
+		"{date}", FormatTime(exportedAt),
+		"{count}", strconv.Itoa(len(save.Cards)),
+	).Replace(name)
 
-	if _, err := os.Stat(folderPath); os.IsNotExist(err) {
-		log.Printf("Creating folder %s\n", folderPath)
-		err = os.Mkdir(folderPath, 0777)
-		if err != nil {
+	return filepath.Join(location.Dir, name)
+}
 
-			log.Fatalf("Failed to create save folder: %v\n", err)
+// Name with the characters that are not safe in file names replaced
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '-'
 		}
-	}
+		return r
+	}, name)
+}
+
+// Writes the save to its location, creating any missing folders. Returns the path that it was written to.
+func SaveCards(save *Save, location SaveLocation) string {
+	exportPath := location.Path(save)
 
-	err := ioutil.WriteFile(exportPath, file, 0644)
+	err := WriteSave(exportPath, save)
 	if err != nil {
 		log.Fatalf("Failed to save file to %s: %v\n", exportPath, err)
 	}